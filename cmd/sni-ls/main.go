@@ -0,0 +1,161 @@
+// Command sni-ls lists StatusNotifierItems registered on the session bus.
+//
+//	sni-ls [--json] [--watch]
+//
+// With --watch it keeps running and prints items as they are added,
+// removed or changed. With --json every item or event is printed as a JSON
+// object on its own line.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/sni"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print JSON objects, one per line")
+	watch := flag.Bool("watch", false, "keep running and print changes of items")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [--json] [--watch]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	log.SetFlags(0)
+	log.SetPrefix("sni-ls: ")
+	if err := run(*jsonOutput, *watch); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// item is what's printed about an item.
+type item struct {
+	Service  string          `json:"service"`
+	Path     dbus.ObjectPath `json:"path"`
+	Id       string          `json:"id"`
+	Title    string          `json:"title"`
+	Category sni.Category    `json:"category"`
+	Status   sni.Status      `json:"status"`
+	IconName string          `json:"icon_name"`
+	Menu     dbus.ObjectPath `json:"menu"`
+}
+
+func newItem(i *host.Item) item {
+	props := i.Properties()
+	return item{
+		Service:  i.Service(),
+		Path:     i.Path(),
+		Id:       props.Id,
+		Title:    props.Title,
+		Category: props.Category,
+		Status:   props.Status,
+		IconName: props.IconName,
+		Menu:     props.Menu,
+	}
+}
+
+func run(jsonOutput, watch bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	h := host.New(conn)
+	defer h.Close()
+	if err := h.Watch(ctx); err != nil {
+		return err
+	}
+
+	if !watch {
+		var items []item
+		for _, i := range h.Items() {
+			items = append(items, newItem(i))
+		}
+		return printItems(os.Stdout, items, jsonOutput)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-h.Events():
+			if !ok {
+				return nil
+			}
+			if err := printEvent(os.Stdout, e.Type, newItem(e.Item), jsonOutput); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// printItems prints items as a table or as JSON objects, one per line.
+func printItems(w io.Writer, items []item, jsonOutput bool) error {
+	if jsonOutput {
+		return printJSON(w, items)
+	}
+	return printTable(w, items)
+}
+
+func printJSON(w io.Writer, items []item) error {
+	enc := json.NewEncoder(w)
+	for _, i := range items {
+		if err := enc.Encode(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printTable(w io.Writer, items []item) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tPATH\tID\tTITLE\tCATEGORY\tSTATUS\tICON\tMENU")
+	for _, i := range items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			i.Service, i.Path, orDash(i.Id), orDash(i.Title), orDash(string(i.Category)),
+			orDash(string(i.Status)), orDash(i.IconName), orDash(string(i.Menu)))
+	}
+	return tw.Flush()
+}
+
+// printEvent prints that item i was added, removed or changed.
+func printEvent(w io.Writer, typ host.EventType, i item, jsonOutput bool) error {
+	if jsonOutput {
+		return json.NewEncoder(w).Encode(struct {
+			Event string `json:"event"`
+			Item  item   `json:"item"`
+		}{typ.String(), i})
+	}
+	_, err := fmt.Fprintf(w, "%-8s %s%s id=%q title=%q category=%s status=%s icon=%q menu=%s\n",
+		typ, i.Service, i.Path, i.Id, i.Title, orDash(string(i.Category)),
+		orDash(string(i.Status)), i.IconName, orDash(string(i.Menu)))
+	return err
+}
+
+// orDash makes empty columns visible.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
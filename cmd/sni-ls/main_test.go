@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/sni"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testItems = []item{
+	{
+		Service:  ":1.42",
+		Path:     "/StatusNotifierItem",
+		Id:       "player",
+		Title:    "Player",
+		Category: sni.CategoryApplicationStatus,
+		Status:   sni.StatusActive,
+		IconName: "media-playback-start",
+		Menu:     "/MenuBar",
+	},
+	{Service: "org.example.Mail", Path: "/org/ayatana/NotificationItem/mail"},
+}
+
+func TestPrintItems(t *testing.T) {
+	tests := []struct {
+		name       string
+		jsonOutput bool
+		want       []string
+	}{
+		{
+			name: "table",
+			want: []string{
+				"SERVICE           PATH                                ID      TITLE   CATEGORY           STATUS  ICON                  MENU",
+				":1.42             /StatusNotifierItem                 player  Player  ApplicationStatus  Active  media-playback-start  /MenuBar",
+				"org.example.Mail  /org/ayatana/NotificationItem/mail  -       -       -                  -       -                     -",
+			},
+		},
+		{
+			name:       "json",
+			jsonOutput: true,
+			want: []string{
+				`{"service":":1.42","path":"/StatusNotifierItem","id":"player","title":"Player",` +
+					`"category":"ApplicationStatus","status":"Active","icon_name":"media-playback-start","menu":"/MenuBar"}`,
+				`{"service":"org.example.Mail","path":"/org/ayatana/NotificationItem/mail","id":"","title":"",` +
+					`"category":"","status":"","icon_name":"","menu":""}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			require.NoError(t, printItems(&b, testItems, tt.jsonOutput))
+			assert.Equal(t, strings.Join(tt.want, "\n")+"\n", b.String())
+		})
+	}
+}
+
+func TestPrintEvent(t *testing.T) {
+	tests := []struct {
+		typ        host.EventType
+		item       item
+		jsonOutput bool
+		want       string
+	}{
+		{
+			typ:  host.ItemAdded,
+			item: testItems[0],
+			want: `added    :1.42/StatusNotifierItem id="player" title="Player" ` +
+				`category=ApplicationStatus status=Active icon="media-playback-start" menu=/MenuBar`,
+		},
+		{
+			typ:  host.ItemRemoved,
+			item: testItems[1],
+			want: `removed  org.example.Mail/org/ayatana/NotificationItem/mail id="" title="" ` +
+				`category=- status=- icon="" menu=-`,
+		},
+		{
+			typ:        host.ItemChanged,
+			item:       testItems[1],
+			jsonOutput: true,
+			want: `{"event":"changed","item":{"service":"org.example.Mail",` +
+				`"path":"/org/ayatana/NotificationItem/mail","id":"","title":"",` +
+				`"category":"","status":"","icon_name":"","menu":""}}`,
+		},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		require.NoError(t, printEvent(&b, tt.typ, tt.item, tt.jsonOutput))
+		assert.Equal(t, tt.want+"\n", b.String())
+	}
+}
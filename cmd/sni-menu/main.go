@@ -0,0 +1,190 @@
+// Command sni-menu prints and clicks menus of StatusNotifierItems.
+//
+//	sni-menu [dump] ITEM
+//	sni-menu click ITEM ID|LABEL/PATH
+//
+// ITEM is the Id property of an item, e.g. "MyApp", or a bus name with an
+// optional object path as registered in the watcher, see sni-ls. Items are
+// clicked by id or by labels separated by slashes, e.g. "File/Quit".
+// Labels are written without mnemonic underscores.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knightpp/sni/generated/status_notifier_watcher"
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout of D-Bus calls")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: %s [flags] [dump] ITEM\n", os.Args[0])
+		fmt.Fprintf(out, "       %s [flags] click ITEM ID|LABEL/PATH\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("sni-menu: ")
+	args := flag.Args()
+	command := "dump"
+	if len(args) > 0 && (args[0] == "dump" || args[0] == "click") {
+		command, args = args[0], args[1:]
+	}
+	if (command == "dump" && len(args) != 1) || (command == "click" && len(args) != 2) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := run(ctx, command, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, command string, args []string) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	item, err := findItem(ctx, conn, args[0])
+	if err != nil {
+		return err
+	}
+	props := item.Properties()
+	if props.Menu == "" {
+		return fmt.Errorf("item %s has no menu", item.ID())
+	}
+	client := menu.NewClient(conn, item.Service(), props.Menu)
+	if err := client.Refresh(ctx); err != nil {
+		return fmt.Errorf("item %s: %w", item.ID(), err)
+	}
+
+	switch command {
+	case "click":
+		target, err := findMenuItem(client.Root(), args[1])
+		if err != nil {
+			return err
+		}
+		return client.Click(ctx, target.ID)
+	default:
+		dump(os.Stdout, client.Root(), 0)
+		return nil
+	}
+}
+
+// findItem returns registered item by Id property or by the string it's
+// registered with. Items not known to the watcher can be given by bus name.
+func findItem(ctx context.Context, conn *dbus.Conn, name string) (*host.Item, error) {
+	w := status_notifier_watcher.NewStatusNotifierWatcher(conn.Object(watcher.Name, watcher.Path))
+	ids, err := w.GetRegisteredStatusNotifierItems(ctx)
+	if err != nil {
+		log.Printf("couldn't list items: %v", err)
+	}
+	var byID *host.Item
+	for _, id := range ids {
+		item := host.NewItem(conn, id)
+		if id == name || item.Service() == name {
+			return item, item.Refresh(ctx)
+		}
+		if err := item.Refresh(ctx); err != nil {
+			continue
+		}
+		if item.Properties().Id == name && byID == nil {
+			byID = item
+		}
+	}
+	if byID != nil {
+		return byID, nil
+	}
+	if strings.HasPrefix(name, ":") || strings.Contains(name, ".") {
+		item := host.NewItem(conn, name)
+		return item, item.Refresh(ctx)
+	}
+	return nil, fmt.Errorf("no item %q, see sni-ls", name)
+}
+
+// findMenuItem returns item by id or by label path.
+func findMenuItem(root *menu.RemoteItem, target string) (*menu.RemoteItem, error) {
+	var item *menu.RemoteItem
+	var ok bool
+	if id, err := strconv.ParseInt(target, 10, 32); err == nil {
+		item, ok = root.Find(int32(id))
+	} else {
+		item, ok = root.FindLabel(strings.Split(target, "/")...)
+	}
+	if !ok {
+		return nil, fmt.Errorf("no menu item %q", target)
+	}
+	if !item.Enabled {
+		return nil, fmt.Errorf("menu item %q is disabled", target)
+	}
+	return item, nil
+}
+
+// dump prints children of item indented by depth, one per line, e.g.
+//
+//	3 [x] Dark mode
+//	4     Quit (Ctrl+Q)
+func dump(w io.Writer, item *menu.RemoteItem, depth int) {
+	for _, child := range item.Children {
+		indent := strings.Repeat("  ", depth)
+		if child.IsSeparator() {
+			fmt.Fprintf(w, "%s%d ----\n", indent, child.ID)
+			continue
+		}
+		line := fmt.Sprintf("%s%d %s %s", indent, child.ID, toggle(child), child.Label)
+		if len(child.Shortcut) > 0 {
+			line += " (" + child.Shortcut.String() + ")"
+		}
+		if !child.Enabled {
+			line += " [disabled]"
+		}
+		if !child.Visible {
+			line += " [hidden]"
+		}
+		if child.HasSubmenu() {
+			line += " >"
+		}
+		fmt.Fprintln(w, line)
+		dump(w, child, depth+1)
+	}
+}
+
+// toggleMarks are marks for off, on and indeterminate states.
+var toggleMarks = map[menu.ToggleType][3]string{
+	menu.ToggleTypeCheckmark: {"[ ]", "[x]", "[-]"},
+	menu.ToggleTypeRadio:     {"( )", "(*)", "(-)"},
+}
+
+func toggle(item *menu.RemoteItem) string {
+	m, ok := toggleMarks[item.ToggleType]
+	if !ok {
+		return "   "
+	}
+	switch item.ToggleState {
+	case 0:
+		return m[0]
+	case 1:
+		return m[1]
+	default:
+		return m[2]
+	}
+}
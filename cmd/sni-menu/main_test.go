@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMenu(t *testing.T) *menu.RemoteItem {
+	t.Helper()
+	tree := menu.NewItem().Submenu(
+		menu.NewItem().Label("_File").Submenu(
+			menu.NewItem().Label("_Open").Shortcut([][]string{{"Control", "o"}}),
+			menu.NewItem().Separator(true),
+			menu.NewItem().Label("_Quit").CanBeActivated(false),
+		),
+		menu.NewItem().Label("Dark mode").ToggleType(menu.ToggleTypeCheckmark).ToggleState(true),
+		menu.NewItem().Label("Light").ToggleType(menu.ToggleTypeRadio).ToggleState(false),
+		menu.NewItem().Label("Hidden").Visible(false),
+	).Build()
+	root, err := menu.DecodeLayout(tree.ToLayout())
+	require.NoError(t, err)
+	return root
+}
+
+func TestFindMenuItem(t *testing.T) {
+	root := testMenu(t)
+	tests := []struct {
+		target string
+		label  string
+		err    string
+	}{
+		{target: "1", label: "_File"},
+		{target: "2", label: "_Open"},
+		{target: "File/Open", label: "_Open"},
+		{target: "Dark mode", label: "Dark mode"},
+		{target: "File/Quit", err: `menu item "File/Quit" is disabled`},
+		{target: "4", err: `menu item "4" is disabled`},
+		{target: "File/Save", err: `no menu item "File/Save"`},
+		{target: "42", err: `no menu item "42"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			item, err := findMenuItem(root, tt.target)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.label, item.Label)
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	var b strings.Builder
+	dump(&b, testMenu(t), 0)
+	assert.Equal(t, strings.Join([]string{
+		"1     _File >",
+		"  2     _Open (Ctrl+o)",
+		"  3 ----",
+		"  4     _Quit [disabled]",
+		"5 [x] Dark mode",
+		"6 ( ) Light",
+		"7     Hidden [hidden]",
+		"",
+	}, "\n"), b.String())
+}
+
+func TestToggle(t *testing.T) {
+	tests := []struct {
+		toggleType menu.ToggleType
+		state      int32
+		want       string
+	}{
+		{menu.ToggleTypeCheckmark, 0, "[ ]"},
+		{menu.ToggleTypeCheckmark, 1, "[x]"},
+		{menu.ToggleTypeCheckmark, -1, "[-]"},
+		{menu.ToggleTypeRadio, 0, "( )"},
+		{menu.ToggleTypeRadio, 1, "(*)"},
+		{menu.ToggleTypeRadio, -1, "(-)"},
+		{"", 1, "   "},
+	}
+	for _, tt := range tests {
+		item := &menu.RemoteItem{ToggleType: tt.toggleType, ToggleState: tt.state}
+		assert.Equal(t, tt.want, toggle(item), "%q %d", tt.toggleType, tt.state)
+	}
+}
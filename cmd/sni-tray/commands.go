@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/knightpp/sni/pkg/icontheme"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/sni"
+	"github.com/knightpp/sni/pkg/tray"
+)
+
+// command is a line read from stdin, e.g. "icon:mail-unread".
+type command struct {
+	name  string
+	value string
+}
+
+// parseCommand parses "name:value" line, "quit" has no value. Empty lines
+// are skipped.
+func parseCommand(line string) (command, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return command{}, false
+	}
+	name, value, _ := strings.Cut(line, ":")
+	return command{strings.TrimSpace(name), value}, true
+}
+
+// entry is a menu entry, an entry without label is a separator.
+type entry struct {
+	label   string
+	command string
+}
+
+// parseMenu parses entries like "Open!xdg-open ." and "---".
+func parseMenu(specs []string) ([]entry, error) {
+	var entries []entry
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		switch {
+		case spec == "":
+			continue
+		case spec == "---":
+			entries = append(entries, entry{})
+			continue
+		}
+		label, cmd, ok := strings.Cut(spec, "!")
+		if !ok || label == "" {
+			return nil, fmt.Errorf("menu entry %q: expected LABEL!COMMAND", spec)
+		}
+		entries = append(entries, entry{label: label, command: cmd})
+	}
+	return entries, nil
+}
+
+func buildMenu(entries []entry, quit func()) menu.ItemTree {
+	items := make([]*menu.Item, 0, len(entries))
+	for _, e := range entries {
+		e := e
+		if e.label == "" {
+			items = append(items, menu.NewItem().Separator(true))
+			continue
+		}
+		item := menu.NewItem().Label(e.label)
+		if e.command == "quit" {
+			item.OnClick(quit)
+		} else {
+			item.OnClick(func() { runCommand(e.command) })
+		}
+		items = append(items, item)
+	}
+	return menu.NewItem().Submenu(items...).Build()
+}
+
+// runCommand starts cmd with sh and doesn't wait for it to finish.
+func runCommand(cmd string) {
+	c := exec.Command("sh", "-c", cmd)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	if err := c.Start(); err != nil {
+		log.Printf("%q: %v", cmd, err)
+		return
+	}
+	go func() {
+		if err := c.Wait(); err != nil {
+			log.Printf("%q: %v", cmd, err)
+		}
+	}()
+}
+
+// state applies commands to the tray. Once the tray is set up, commands are
+// applied on the goroutine that runs menu handlers, see run.
+type state struct {
+	tray *tray.Tray
+	quit context.CancelFunc
+	// started is set after Setup, then changes are signalled to hosts
+	started bool
+}
+
+func (s *state) apply(c command) error {
+	t := s.tray
+	var signal func() error
+	switch c.name {
+	case "icon":
+		if err := setIcon(t, c.value); err != nil {
+			return err
+		}
+		signal = t.SignalNewIcon
+	case "title":
+		t.SetTitle(c.value)
+		signal = t.SignalNewTitle
+	case "tooltip":
+		t.SetToolTipRaw(tray.ToolTip{Third: c.value})
+		signal = t.SignalNewToolTip
+	case "status":
+		status := sni.Status(c.value)
+		switch status {
+		case sni.StatusPassive, sni.StatusActive, sni.StatusNeedsAttention:
+		default:
+			return fmt.Errorf("unknown status %q", c.value)
+		}
+		t.SetSniStatus(status)
+		signal = t.SignalNewStatus
+	case "menu":
+		entries, err := parseMenu(strings.Split(c.value, "|"))
+		if err != nil {
+			return err
+		}
+		t.SetMenu(buildMenu(entries, s.quit))
+	case "quit":
+		s.quit()
+	default:
+		return fmt.Errorf("unknown command %q", c.name)
+	}
+	if s.started && signal != nil {
+		return signal()
+	}
+	return nil
+}
+
+// setIcon sets icon by name or loads it from a file if value is a path.
+func setIcon(t *tray.Tray, value string) error {
+	if !strings.ContainsRune(value, '/') {
+		t.SetIconName(value)
+		t.SetIconPixmapRaw(nil)
+		return nil
+	}
+	img, err := icontheme.Load(value)
+	if err != nil {
+		return err
+	}
+	t.SetIconName("")
+	t.SetIconPixmap(img)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want command
+		ok   bool
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "quit", want: command{name: "quit"}, ok: true},
+		{line: "icon:mail-unread", want: command{"icon", "mail-unread"}, ok: true},
+		{line: " title : Backup ", want: command{"title", " Backup"}, ok: true},
+		{line: "tooltip:a:b", want: command{"tooltip", "a:b"}, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseCommand(tt.line)
+		assert.Equal(t, tt.ok, ok, "%q", tt.line)
+		assert.Equal(t, tt.want, got, "%q", tt.line)
+	}
+}
+
+func TestParseMenu(t *testing.T) {
+	tests := []struct {
+		specs []string
+		want  []entry
+		err   string
+	}{
+		{specs: nil, want: nil},
+		{specs: []string{"", "  "}, want: nil},
+		{
+			specs: []string{"Open!xdg-open .", " --- ", "Quit!quit"},
+			want:  []entry{{"Open", "xdg-open ."}, {}, {"Quit", "quit"}},
+		},
+		{specs: []string{"Run!echo a!b"}, want: []entry{{"Run", "echo a!b"}}},
+		{specs: []string{"Open"}, err: `menu entry "Open": expected LABEL!COMMAND`},
+		{specs: []string{"!quit"}, err: `menu entry "!quit": expected LABEL!COMMAND`},
+	}
+	for _, tt := range tests {
+		got, err := parseMenu(tt.specs)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%q", tt.specs)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tray.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+id = "backup"
+title = "Backup"
+icon = "drive-harddisk"
+status = "Active"
+menu = ["Log!xdg-open backup.log", "---", "Quit!quit"]
+`), 0o644))
+
+	defaults := config{ID: "sni-tray", Title: "sni-tray", Icon: "dialog-information"}
+	fromFile := config{
+		ID:     "backup",
+		Title:  "Backup",
+		Icon:   "drive-harddisk",
+		Status: "Active",
+		Menu:   []string{"Log!xdg-open backup.log", "---", "Quit!quit"},
+	}
+	tests := []struct {
+		name string
+		args []string
+		want config
+		err  bool
+	}{
+		{name: "defaults", want: defaults},
+		{
+			name: "flags",
+			args: []string{"--id", "x", "--tooltip", "Hi", "--command", "true", "--menu", "A!a|Quit!quit"},
+			want: config{ID: "x", Title: "sni-tray", Icon: "dialog-information",
+				ToolTip: "Hi", Command: "true", Menu: []string{"A!a", "Quit!quit"}},
+		},
+		{name: "config", args: []string{"--config", path}, want: fromFile},
+		{
+			name: "flags override config",
+			args: []string{"--title", "Sync", "--config", path, "--menu", "Quit!quit"},
+			want: config{ID: "backup", Title: "Sync", Icon: "drive-harddisk",
+				Status: "Active", Menu: []string{"Quit!quit"}},
+		},
+		{
+			name: "flags set to defaults override config",
+			args: []string{"--config", path, "--id", "sni-tray"},
+			want: config{ID: "sni-tray", Title: "Backup", Icon: "drive-harddisk",
+				Status: "Active", Menu: fromFile.Menu},
+		},
+		{name: "argument", args: []string{"extra"}, err: true},
+		{name: "unknown flag", args: []string{"--nope"}, err: true},
+		{name: "missing config", args: []string{"--config", path + ".missing"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFlags(tt.args)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Command sni-tray shows a tray icon for shell scripts.
+//
+//	sni-tray [--config FILE] [--id ID] [--title TITLE] [--icon NAME|FILE]
+//	         [--tooltip TEXT] [--status STATUS] [--command CMD]
+//	         [--menu 'Label!cmd|---|Quit!quit']
+//
+// Menu entries are separated by "|", an entry is a label and a shell
+// command separated by "!". "---" is a separator and the command "quit"
+// exits sni-tray. --command runs when the icon is activated, e.g. clicked.
+//
+// Lines read from stdin update the icon while it's shown:
+//
+//	icon:NAME|FILE
+//	title:TEXT
+//	tooltip:TEXT
+//	status:Passive|Active|NeedsAttention
+//	menu:ENTRIES
+//	quit
+//
+// The config file is TOML with keys named like the flags, menu is a list of
+// entries. Flags override the config file.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/knightpp/sni/pkg/dispatch"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is the initial state of the icon.
+type config struct {
+	ID      string   `toml:"id"`
+	Title   string   `toml:"title"`
+	Icon    string   `toml:"icon"`
+	ToolTip string   `toml:"tooltip"`
+	Status  string   `toml:"status"`
+	Command string   `toml:"command"`
+	Menu    []string `toml:"menu"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sni-tray: ")
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	if err := run(cfg, os.Stdin); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseFlags(args []string) (config, error) {
+	cfg := config{ID: "sni-tray", Title: "sni-tray", Icon: "dialog-information"}
+	fs := flag.NewFlagSet("sni-tray", flag.ContinueOnError)
+	path := fs.String("config", "", "TOML `file` with the initial state")
+	id := fs.String("id", cfg.ID, "Id of the item")
+	title := fs.String("title", cfg.Title, "title of the item")
+	icon := fs.String("icon", cfg.Icon, "icon `name` or path to an image file")
+	tooltip := fs.String("tooltip", "", "tooltip `text`")
+	status := fs.String("status", "", "Passive, Active or NeedsAttention")
+	command := fs.String("command", "", "shell `command` to run when the icon is activated")
+	menuSpec := fs.String("menu", "", "menu `entries`, e.g. 'Open!xdg-open .|---|Quit!quit'")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *path != "" {
+		if _, err := toml.DecodeFile(*path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "id":
+			cfg.ID = *id
+		case "title":
+			cfg.Title = *title
+		case "icon":
+			cfg.Icon = *icon
+		case "tooltip":
+			cfg.ToolTip = *tooltip
+		case "status":
+			cfg.Status = *status
+		case "command":
+			cfg.Command = *command
+		case "menu":
+			cfg.Menu = strings.Split(*menuSpec, "|")
+		}
+	})
+	return cfg, nil
+}
+
+func run(cfg config, stdin io.Reader) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, quit := context.WithCancel(ctx)
+	defer quit()
+
+	entries, err := parseMenu(cfg.Menu)
+	if err != nil {
+		return err
+	}
+	// Menu handlers and commands read from stdin run on the worker, so
+	// SetMenu doesn't race with clicks.
+	worker := dispatch.NewWorker()
+	defer worker.Close()
+	t, err := tray.NewTray(cfg.ID, cfg.Title, buildMenu(entries, quit))
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	t.SetFallbackWatcher(true).SetDispatcher(worker)
+	if cfg.Command != "" {
+		t.OnActivate(func(x, y int32) { runCommand(cfg.Command) })
+	}
+	s := &state{tray: t, quit: quit}
+	initial := []command{{"icon", cfg.Icon}, {"tooltip", cfg.ToolTip}}
+	if cfg.Status != "" {
+		initial = append(initial, command{"status", cfg.Status})
+	}
+	for _, c := range initial {
+		if err := s.apply(c); err != nil {
+			return err
+		}
+	}
+	if err := t.Setup(); err != nil {
+		return err
+	}
+	s.started = true
+
+	go func() {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			c, ok := parseCommand(scanner.Text())
+			if !ok {
+				continue
+			}
+			worker.Dispatch(func() {
+				if err := s.apply(c); err != nil {
+					log.Print(err)
+				}
+			})
+		}
+		if err := scanner.Err(); err != nil {
+			log.Print(err)
+		}
+	}()
+	<-ctx.Done()
+	return nil
+}
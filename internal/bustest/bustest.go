@@ -0,0 +1,49 @@
+// Package bustest runs private session buses for tests.
+package bustest
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Start runs a private session bus until the test finishes and returns its
+// address. The test is skipped if dbus-daemon isn't installed.
+func Start(t testing.TB) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("bustest: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("bustest: couldn't start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("bustest: couldn't read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// Connect returns a new connection to the bus at address, it's closed when
+// the test finishes.
+func Connect(t testing.TB, address string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("bustest: couldn't connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,115 @@
+// Package dispatch decides where callbacks of tray and menu run.
+//
+// D-Bus method calls are handled on goroutines of the D-Bus connection,
+// one per call, so calls that arrive together are dispatched in no
+// particular order. A slow callback run there delays the reply and the
+// panel looks frozen. Synchronous runs callbacks right away, possibly at the
+// same time. Serial and Worker run one callback at a time, Worker does it on
+// its own goroutine. Func adapts executors such as GUI main loops.
+package dispatch
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// Dispatcher runs callbacks.
+type Dispatcher interface {
+	// Dispatch runs fn now or later, possibly on another goroutine.
+	Dispatch(fn func())
+}
+
+// Synchronous runs callbacks right away on the calling goroutine. Errors
+// of menu handlers are returned to the host only with a synchronous
+// dispatcher, such as Synchronous or Serial.
+var Synchronous Dispatcher = Func(func(fn func()) { fn() })
+
+// Serial runs callbacks on the calling goroutine, one at a time: a callback
+// waits for the running one of the same Serial to return. The zero value is
+// ready to use.
+type Serial struct {
+	mu sync.Mutex
+}
+
+func (s *Serial) Dispatch(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+// Func is an adapter to use ordinary functions as Dispatcher, e.g. one that
+// posts fn onto a GUI main loop.
+type Func func(fn func())
+
+func (f Func) Dispatch(fn func()) {
+	f(fn)
+}
+
+// Worker runs callbacks one by one on a dedicated goroutine in order of
+// Dispatch calls. The queue is unbounded, so Dispatch never blocks.
+type Worker struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []func()
+	closed bool
+	done   chan struct{}
+}
+
+// NewWorker starts a worker, stop it with Close.
+func NewWorker() *Worker {
+	w := &Worker{done: make(chan struct{})}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Dispatch queues fn. It does nothing after Close.
+func (w *Worker) Dispatch(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.queue = append(w.queue, fn)
+	w.cond.Signal()
+}
+
+// Close stops accepting callbacks and waits until queued ones have run.
+func (w *Worker) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Signal()
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
+
+func (w *Worker) run() {
+	defer close(w.done)
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		fn := w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+		call(fn)
+	}
+}
+
+// call runs fn, a panic is logged so the worker keeps running.
+func call(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("dispatch: callback panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,81 @@
+package dispatch_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/pkg/dispatch"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerOrder(t *testing.T) {
+	w := dispatch.NewWorker()
+	var got []int
+	for n := 0; n < 100; n++ {
+		n := n
+		w.Dispatch(func() {
+			if n == 50 {
+				panic("worker must survive this")
+			}
+			got = append(got, n)
+		})
+	}
+	assert.NoError(t, w.Close())
+	assert.Len(t, got, 99)
+	for n := 1; n < len(got); n++ {
+		assert.Less(t, got[n-1], got[n])
+	}
+
+	w.Dispatch(func() { t.Error("dispatched after Close") })
+}
+
+func TestSerial(t *testing.T) {
+	var serial dispatch.Serial
+	var running, overlaps int32
+	var wg sync.WaitGroup
+	for n := 0; n < 50; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serial.Dispatch(func() {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				runtime.Gosched()
+				atomic.AddInt32(&running, -1)
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Zero(t, overlaps)
+
+	// Another Serial doesn't wait for a running callback.
+	release := make(chan struct{})
+	go serial.Dispatch(func() { <-release })
+	defer close(release)
+	var other dispatch.Serial
+	ran := make(chan struct{})
+	go other.Dispatch(func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Error("Serial waits for another Serial")
+	}
+}
+
+func TestSynchronousDoesntWait(t *testing.T) {
+	release := make(chan struct{})
+	go dispatch.Synchronous.Dispatch(func() { <-release })
+	defer close(release)
+	ran := make(chan struct{})
+	go dispatch.Synchronous.Dispatch(func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Error("Synchronous waits for a running callback")
+	}
+}
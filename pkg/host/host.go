@@ -0,0 +1,414 @@
+// Package host implements the StatusNotifierHost side of the protocol, it's
+// for panels that show tray items.
+//
+//	h := host.New(conn)
+//	if err := h.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer h.Close()
+//	for e := range h.Events() {
+//		props := e.Item.Properties()
+//		...
+//	}
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/knightpp/sni/generated/d_bus"
+	"github.com/knightpp/sni/generated/status_notifier_watcher"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// instance makes host names unique within the process.
+var instance uint32
+
+// EventType is a kind of Event.
+type EventType int
+
+const (
+	// ItemAdded is sent when an item appears, its properties are fetched.
+	ItemAdded EventType = iota
+	// ItemRemoved is sent when an item goes away.
+	ItemRemoved
+	// ItemChanged is sent after properties of an item are refreshed.
+	ItemChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ItemAdded:
+		return "added"
+	case ItemRemoved:
+		return "removed"
+	case ItemChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event tells about a change of the set of items or of an item.
+type Event struct {
+	Type EventType
+	Item *Item
+}
+
+// Host registers with StatusNotifierWatcher and keeps track of items. When
+// the watcher leaves the bus all items are removed, a restarted watcher
+// brings them back as items register with it.
+type Host struct {
+	conn *dbus.Conn
+	name string
+	// passive is set by Watch before listening starts
+	passive bool
+
+	// mu guards fields below
+	mu    sync.Mutex
+	items []*Item
+	// queue has events not yet received from events
+	queue   []Event
+	wake    chan struct{}
+	closed  bool
+	events  chan Event
+	signals chan *dbus.Signal
+	done    chan struct{}
+}
+
+// New returns Host that uses conn, it doesn't communicate through D-Bus
+// until Start. Host must be closed with Close.
+func New(conn *dbus.Conn) *Host {
+	h := &Host{
+		conn: conn,
+		name: fmt.Sprintf("org.kde.StatusNotifierHost-%d-%d",
+			os.Getpid(), atomic.AddUint32(&instance, 1)),
+		events: make(chan Event),
+		wake:   make(chan struct{}, 1),
+	}
+	go h.deliver()
+	return h
+}
+
+// Name returns the bus name the host registers with.
+func (h *Host) Name() string {
+	return h.name
+}
+
+// Start requests host name, registers with the watcher and fetches
+// registered items. Items are sent to Events as ItemAdded.
+func (h *Host) Start(ctx context.Context) error {
+	if _, err := h.conn.RequestName(h.name, dbus.NameFlagDoNotQueue); err != nil {
+		return err
+	}
+	if err := h.listenSignals(); err != nil {
+		return err
+	}
+	return h.register(ctx)
+}
+
+// Watch is like Start, but the host doesn't register with the watcher, so
+// IsStatusNotifierHostRegistered isn't changed and items keep assuming
+// there is no panel if there's none. It's meant for tools that inspect
+// items.
+func (h *Host) Watch(ctx context.Context) error {
+	h.passive = true
+	if err := h.listenSignals(); err != nil {
+		return err
+	}
+	return h.addRegistered(ctx)
+}
+
+// signalMatches are match rules of signals of the watcher and items.
+var signalMatches = [][]dbus.MatchOption{
+	{dbus.WithMatchInterface(watcher.Name)},
+	{dbus.WithMatchInterface(itemInterface)},
+	{
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, itemInterface),
+	},
+}
+
+// listenSignals subscribes to signals of the watcher and items.
+func (h *Host) listenSignals() error {
+	for _, match := range signalMatches {
+		if err := h.conn.AddMatchSignal(match...); err != nil {
+			return err
+		}
+	}
+	if err := d_bus.AddMatchSignal(h.conn, &d_bus.DBus_NameOwnerChangedSignal{},
+		dbus.WithMatchArg(0, watcher.Name)); err != nil {
+		return err
+	}
+	signals, done := make(chan *dbus.Signal, 16), make(chan struct{})
+	h.mu.Lock()
+	h.signals, h.done = signals, done
+	h.mu.Unlock()
+	h.conn.Signal(signals)
+	go h.listen(signals, done)
+	return nil
+}
+
+// removeMatches removes match rules added by listenSignals.
+func (h *Host) removeMatches() []error {
+	var errs []error
+	for _, match := range signalMatches {
+		if err := h.conn.RemoveMatchSignal(match...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := d_bus.RemoveMatchSignal(h.conn, &d_bus.DBus_NameOwnerChangedSignal{},
+		dbus.WithMatchArg(0, watcher.Name))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// register registers the host with the watcher and adds its items.
+func (h *Host) register(ctx context.Context) error {
+	w := status_notifier_watcher.NewStatusNotifierWatcher(
+		h.conn.Object(watcher.Name, watcher.Path))
+	if err := w.RegisterStatusNotifierHost(ctx, h.name); err != nil {
+		return fmt.Errorf("couldn't register with %s: %w", watcher.Name, err)
+	}
+	return h.addRegistered(ctx)
+}
+
+// addRegistered adds items registered with the watcher.
+func (h *Host) addRegistered(ctx context.Context) error {
+	w := status_notifier_watcher.NewStatusNotifierWatcher(
+		h.conn.Object(watcher.Name, watcher.Path))
+	ids, err := w.GetRegisteredStatusNotifierItems(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		h.add(ctx, id)
+	}
+	return nil
+}
+
+// Events returns channel with changes of items. It's closed by Close.
+func (h *Host) Events() <-chan Event {
+	return h.events
+}
+
+// Items returns known items in order of registration.
+func (h *Host) Items() []*Item {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*Item(nil), h.items...)
+}
+
+// Close stops tracking items and closes Events.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	signals, done := h.signals, h.done
+	h.mu.Unlock()
+	h.notify()
+
+	var errs []error
+	if signals != nil {
+		if h.conn.Connected() {
+			h.conn.RemoveSignal(signals)
+			close(signals)
+			errs = append(errs, h.removeMatches()...)
+		}
+		<-done
+	}
+	if h.conn.Connected() && !h.passive {
+		if _, err := h.conn.ReleaseName(h.name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// add fetches properties of item id and adds it.
+func (h *Host) add(ctx context.Context, id string) {
+	h.mu.Lock()
+	for _, item := range h.items {
+		if item.id == id {
+			h.mu.Unlock()
+			return
+		}
+	}
+	h.mu.Unlock()
+
+	item := newItem(h.conn, id)
+	bus := d_bus.NewDBus(h.conn.BusObject())
+	owner, err := bus.GetNameOwner(ctx, item.service)
+	if err != nil {
+		log.Printf("item %s: %v", id, err)
+		return
+	}
+	item.owner = owner
+	if err := item.Refresh(ctx); err != nil {
+		log.Print(err)
+		return
+	}
+
+	h.mu.Lock()
+	h.items = append(h.items, item)
+	h.mu.Unlock()
+	h.send(Event{Type: ItemAdded, Item: item})
+}
+
+func (h *Host) remove(id string) {
+	h.mu.Lock()
+	var removed *Item
+	for n, item := range h.items {
+		if item.id == id {
+			removed = item
+			h.items = append(h.items[:n:n], h.items[n+1:]...)
+			break
+		}
+	}
+	h.mu.Unlock()
+	if removed != nil {
+		h.send(Event{Type: ItemRemoved, Item: removed})
+	}
+}
+
+// removeAll removes all items.
+func (h *Host) removeAll() {
+	h.mu.Lock()
+	removed := h.items
+	h.items = nil
+	h.mu.Unlock()
+	for _, item := range removed {
+		h.send(Event{Type: ItemRemoved, Item: item})
+	}
+}
+
+// refresh refreshes items that sent a signal from path.
+func (h *Host) refresh(sender string, path dbus.ObjectPath) {
+	for _, item := range h.Items() {
+		if item.owner != sender || item.path != path {
+			continue
+		}
+		if err := item.Refresh(context.Background()); err != nil {
+			log.Print(err)
+			continue
+		}
+		h.send(Event{Type: ItemChanged, Item: item})
+	}
+}
+
+func (h *Host) listen(signals <-chan *dbus.Signal, done chan struct{}) {
+	defer close(done)
+	for sig := range signals {
+		switch sig.Name {
+		case watcher.Name + ".StatusNotifierItemRegistered":
+			if id, ok := stringArg(sig); ok {
+				h.add(context.Background(), id)
+			}
+		case watcher.Name + ".StatusNotifierItemUnregistered":
+			if id, ok := stringArg(sig); ok {
+				h.remove(id)
+			}
+		case "org.freedesktop.DBus.NameOwnerChanged":
+			s, err := d_bus.LookupSignal(sig)
+			if err != nil {
+				continue
+			}
+			body := s.(*d_bus.DBus_NameOwnerChangedSignal).Body
+			switch {
+			case body.V0 != watcher.Name:
+			case body.V2 == "":
+				// Items are known only through the watcher, they register
+				// with the next one.
+				h.removeAll()
+			case h.passive:
+				if err := h.addRegistered(context.Background()); err != nil {
+					log.Print(err)
+				}
+			default:
+				// The watcher was restarted and doesn't know the host.
+				if err := h.register(context.Background()); err != nil {
+					log.Print(err)
+				}
+			}
+		case "org.freedesktop.DBus.Properties.PropertiesChanged",
+			itemInterface + ".NewTitle",
+			itemInterface + ".NewIcon",
+			itemInterface + ".NewAttentionIcon",
+			itemInterface + ".NewOverlayIcon",
+			itemInterface + ".NewToolTip",
+			itemInterface + ".NewStatus":
+			h.refresh(sig.Sender, sig.Path)
+		}
+	}
+}
+
+func stringArg(sig *dbus.Signal) (string, bool) {
+	if len(sig.Body) == 0 {
+		return "", false
+	}
+	s, ok := sig.Body[0].(string)
+	return s, ok
+}
+
+// send queues e, events are delivered in order without blocking listen.
+func (h *Host) send(e Event) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.queue = append(h.queue, e)
+	h.mu.Unlock()
+	h.notify()
+}
+
+func (h *Host) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver moves events from queue to events until Close.
+func (h *Host) deliver() {
+	defer close(h.events)
+	for {
+		h.mu.Lock()
+		closed := h.closed
+		var e Event
+		ok := len(h.queue) > 0
+		if ok {
+			e = h.queue[0]
+			h.queue = h.queue[1:]
+		}
+		h.mu.Unlock()
+		if closed {
+			return
+		}
+		if !ok {
+			<-h.wake
+			continue
+		}
+		select {
+		case h.events <- e:
+		case <-h.wake:
+			// Close was called or more events came, put e back.
+			h.mu.Lock()
+			h.queue = append([]Event{e}, h.queue...)
+			h.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package host_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/generated/status_notifier_watcher"
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/sni"
+	"github.com/knightpp/sni/pkg/tray"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, h *host.Host) host.Event {
+	t.Helper()
+	select {
+	case e := <-h.Events():
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+		return host.Event{}
+	}
+}
+
+func TestHost(t *testing.T) {
+	assert := assert.New(t)
+	address := bustest.Start(t)
+	watcherConn := bustest.Connect(t, address)
+	require.NoError(t, watcher.New(watcherConn).Start())
+
+	first := tray.NewTrayWithConn(bustest.Connect(t, address), "first", "First", menu.NewItem().Build())
+	require.NoError(t, first.Setup())
+
+	h := host.New(bustest.Connect(t, address))
+	require.NoError(t, h.Start(context.Background()))
+	defer h.Close()
+
+	e := nextEvent(t, h)
+	assert.Equal(host.ItemAdded, e.Type)
+	props := e.Item.Properties()
+	assert.Equal("first", props.Id)
+	assert.Equal("First", props.Title)
+	assert.Equal(sni.CategoryApplicationStatus, props.Category)
+	assert.Equal(dbus.ObjectPath(tray.MENU_PATH), props.Menu)
+
+	second := tray.NewTrayWithConn(bustest.Connect(t, address), "second", "Second", menu.NewItem().Build())
+	require.NoError(t, second.Setup())
+	e = nextEvent(t, h)
+	assert.Equal(host.ItemAdded, e.Type)
+	assert.Equal("second", e.Item.Properties().Id)
+
+	second.SetTitle("Renamed")
+	require.NoError(t, second.SignalNewTitle())
+	e = nextEvent(t, h)
+	assert.Equal(host.ItemChanged, e.Type)
+	assert.Equal("Renamed", e.Item.Properties().Title)
+	select {
+	case <-e.Item.Changed():
+	default:
+		t.Error("item didn't notify about the change")
+	}
+
+	require.NoError(t, first.Close())
+	e = nextEvent(t, h)
+	assert.Equal(host.ItemRemoved, e.Type)
+	assert.Equal("first", e.Item.Properties().Id)
+	assert.Len(h.Items(), 1)
+
+	// Items go away with the watcher.
+	require.NoError(t, watcherConn.Close())
+	e = nextEvent(t, h)
+	assert.Equal(host.ItemRemoved, e.Type)
+	assert.Equal("second", e.Item.Properties().Id)
+	assert.Empty(h.Items())
+}
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+	address := bustest.Start(t)
+	require.NoError(t, watcher.New(bustest.Connect(t, address)).Start())
+
+	item := tray.NewTrayWithConn(bustest.Connect(t, address), "first", "First", menu.NewItem().Build())
+	require.NoError(t, item.Setup())
+
+	ctx := context.Background()
+	conn := bustest.Connect(t, address)
+	h := host.New(conn)
+	require.NoError(t, h.Watch(ctx))
+	defer h.Close()
+
+	e := nextEvent(t, h)
+	assert.Equal(host.ItemAdded, e.Type)
+	assert.Equal("first", e.Item.Properties().Id)
+
+	w := status_notifier_watcher.NewStatusNotifierWatcher(conn.Object(watcher.Name, watcher.Path))
+	registered, err := w.GetIsStatusNotifierHostRegistered(ctx)
+	require.NoError(t, err)
+	assert.False(registered)
+
+	require.NoError(t, item.Close())
+	e = nextEvent(t, h)
+	assert.Equal(host.ItemRemoved, e.Type)
+}
+
+func TestCloseRemovesMatches(t *testing.T) {
+	address := bustest.Start(t)
+	require.NoError(t, watcher.New(bustest.Connect(t, address)).Start())
+	item := tray.NewTrayWithConn(bustest.Connect(t, address), "first", "First", menu.NewItem().Build())
+	require.NoError(t, item.Setup())
+
+	conn := bustest.Connect(t, address)
+	h := host.New(conn)
+	require.NoError(t, h.Start(context.Background()))
+	nextEvent(t, h)
+	require.NoError(t, h.Close())
+
+	// The connection doesn't get signals of items anymore.
+	sigs := make(chan *dbus.Signal, 10)
+	conn.Signal(sigs)
+	require.NoError(t, item.SignalNewTitle())
+	select {
+	case sig := <-sigs:
+		t.Errorf("got %s after Close", sig.Name)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestItemClient(t *testing.T) {
+	assert := assert.New(t)
+	address := bustest.Start(t)
+	require.NoError(t, watcher.New(bustest.Connect(t, address)).Start())
+
+	icon := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	icon.Set(1, 0, color.NRGBA{R: 0xff, A: 0xff})
+	activated := make(chan [2]int32, 1)
+	scrolled := make(chan string, 1)
+	trayConn := bustest.Connect(t, address)
+	item := tray.NewTrayWithConn(trayConn, "client", "Client", menu.NewItem().Build()).
+		SetIconPixmap(icon).
+		SetToolTipRaw(tray.ToolTip{First: "help", Third: "Title", Fourth: "<b>Text</b>"}).
+		OnActivate(func(x, y int32) { activated <- [2]int32{x, y} }).
+		OnScroll(func(delta int32, orientation string) { scrolled <- orientation })
+	require.NoError(t, item.Setup())
+
+	ctx := context.Background()
+	client := host.NewItem(bustest.Connect(t, address), trayConn.Names()[0]+"/StatusNotifierItem")
+	require.NoError(t, client.Refresh(ctx))
+	props := client.Properties()
+	assert.Equal("Client", props.Title)
+	assert.Equal(host.ToolTip{IconName: "help", Title: "Title", Description: "<b>Text</b>"},
+		props.ParsedToolTip())
+	got := props.Icon(22)
+	require.NotNil(t, got)
+	assert.Equal(icon.Rect, got.Bounds())
+	assert.Equal(color.NRGBA{R: 0xff, A: 0xff}, got.At(1, 0))
+	assert.Equal(color.NRGBA{}, got.At(0, 0))
+
+	require.NoError(t, client.Activate(ctx, 10, 20))
+	assert.Equal([2]int32{10, 20}, <-activated)
+	require.NoError(t, client.Scroll(ctx, 1, "vertical"))
+	assert.Equal("vertical", <-scrolled)
+}
+
+func TestBestPixmap(t *testing.T) {
+	assert := assert.New(t)
+	pixmap := func(size int32) tray.Pixmap {
+		return tray.Pixmap{Width: size, Heigth: size, Data: make([]byte, size*size*4)}
+	}
+	pixmaps := []tray.Pixmap{pixmap(16), pixmap(48), pixmap(24), {Width: 64, Heigth: 64}}
+	assert.Equal(24, host.BestPixmap(pixmaps, 22).Bounds().Dx())
+	assert.Equal(16, host.BestPixmap(pixmaps, 16).Bounds().Dx())
+	assert.Equal(48, host.BestPixmap(pixmaps, 128).Bounds().Dx())
+	assert.Nil(host.BestPixmap(pixmaps[3:], 16))
+}
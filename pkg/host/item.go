@@ -0,0 +1,266 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+
+	"github.com/knightpp/sni/generated/status_notifier_item"
+	"github.com/knightpp/sni/pkg/sni"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	itemInterface = status_notifier_item.InterfaceStatusNotifierItem
+	defaultPath   = dbus.ObjectPath("/StatusNotifierItem")
+)
+
+// Properties are org.kde.StatusNotifierItem properties.
+type Properties struct {
+	Category            sni.Category
+	Id                  string
+	Title               string
+	Status              sni.Status
+	WindowId            int32
+	IconThemePath       string
+	Menu                dbus.ObjectPath
+	ItemIsMenu          bool
+	IconName            string
+	IconPixmap          []tray.Pixmap
+	OverlayIconName     string
+	OverlayIconPixmap   []tray.Pixmap
+	AttentionIconName   string
+	AttentionIconPixmap []tray.Pixmap
+	AttentionMovieName  string
+	ToolTip             tray.ToolTip
+}
+
+// Item is a StatusNotifierItem seen by the host. Properties are cached and
+// refreshed when the item signals changes.
+type Item struct {
+	conn *dbus.Conn
+	// id is the string the item is registered with
+	id      string
+	service string
+	path    dbus.ObjectPath
+	// owner is the unique bus name of service, signals come from it
+	owner string
+
+	mu    sync.RWMutex
+	props Properties
+	// changed is notified after properties are refreshed
+	changed chan struct{}
+}
+
+// parseID splits registered item into bus name and object path, e.g.
+// ":1.42/org/ayatana/NotificationItem/app". Path defaults to
+// /StatusNotifierItem.
+func parseID(id string) (service string, path dbus.ObjectPath) {
+	if i := strings.IndexByte(id, '/'); i >= 0 {
+		return id[:i], dbus.ObjectPath(id[i:])
+	}
+	return id, defaultPath
+}
+
+func newItem(conn *dbus.Conn, id string) *Item {
+	service, path := parseID(id)
+	return &Item{
+		conn:    conn,
+		id:      id,
+		service: service,
+		path:    path,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// ID returns the string the item is registered with in the watcher.
+func (i *Item) ID() string {
+	return i.id
+}
+
+// Service returns bus name of the item.
+func (i *Item) Service() string {
+	return i.service
+}
+
+// Path returns object path of the item.
+func (i *Item) Path() dbus.ObjectPath {
+	return i.path
+}
+
+// Properties returns cached properties.
+func (i *Item) Properties() Properties {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.props
+}
+
+// Changed receives a value after properties of the item change. Changes
+// that come while nobody receives are merged into one.
+func (i *Item) Changed() <-chan struct{} {
+	return i.changed
+}
+
+func (i *Item) object() dbus.BusObject {
+	return i.conn.Object(i.service, i.path)
+}
+
+// Refresh fetches all properties of the item.
+func (i *Item) Refresh(ctx context.Context) error {
+	var all map[string]dbus.Variant
+	err := i.object().CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0,
+		itemInterface).Store(&all)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i.id, err)
+	}
+	var props Properties
+	if err := decodeProperties(all, &props); err != nil {
+		return fmt.Errorf("%s: %w", i.id, err)
+	}
+	i.mu.Lock()
+	i.props = props
+	i.mu.Unlock()
+	select {
+	case i.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// decodeProperties stores known properties into props. Missing properties
+// are left zero, items often don't implement all of them.
+func decodeProperties(all map[string]dbus.Variant, props *Properties) error {
+	fields := map[string]interface{}{
+		"Category":            &props.Category,
+		"Id":                  &props.Id,
+		"Title":               &props.Title,
+		"Status":              &props.Status,
+		"WindowId":            &props.WindowId,
+		"IconThemePath":       &props.IconThemePath,
+		"Menu":                &props.Menu,
+		"ItemIsMenu":          &props.ItemIsMenu,
+		"IconName":            &props.IconName,
+		"IconPixmap":          &props.IconPixmap,
+		"OverlayIconName":     &props.OverlayIconName,
+		"OverlayIconPixmap":   &props.OverlayIconPixmap,
+		"AttentionIconName":   &props.AttentionIconName,
+		"AttentionIconPixmap": &props.AttentionIconPixmap,
+		"AttentionMovieName":  &props.AttentionMovieName,
+		"ToolTip":             &props.ToolTip,
+	}
+	for name, field := range fields {
+		v, ok := all[name]
+		if !ok {
+			continue
+		}
+		if err := v.Store(field); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// NewItem returns a client for the item registered as id, e.g.
+// ":1.42/StatusNotifierItem" or "org.kde.StatusNotifierItem-100-1", without
+// a Host. Call Refresh to fetch properties. Changed isn't notified about
+// changes made by the item itself.
+func NewItem(conn *dbus.Conn, id string) *Item {
+	return newItem(conn, id)
+}
+
+func (i *Item) client() *status_notifier_item.StatusNotifierItem {
+	return status_notifier_item.NewStatusNotifierItem(i.object())
+}
+
+// Activate asks the item to do its primary action, e.g. on a left click.
+// x and y are screen coordinates of the click.
+func (i *Item) Activate(ctx context.Context, x, y int32) error {
+	return i.client().Activate(ctx, x, y)
+}
+
+// SecondaryActivate asks the item to do its secondary action, e.g. on a
+// middle click.
+func (i *Item) SecondaryActivate(ctx context.Context, x, y int32) error {
+	return i.client().SecondaryActivate(ctx, x, y)
+}
+
+// ContextMenu asks the item to show its context menu. Items with Menu
+// property expect the host to show that menu instead.
+func (i *Item) ContextMenu(ctx context.Context, x, y int32) error {
+	return i.client().ContextMenu(ctx, x, y)
+}
+
+// Scroll tells the item about mouse wheel, orientation is "horizontal" or
+// "vertical".
+func (i *Item) Scroll(ctx context.Context, delta int32, orientation string) error {
+	return i.client().Scroll(ctx, delta, orientation)
+}
+
+// ToolTip is a parsed tooltip of an item.
+type ToolTip struct {
+	IconName   string
+	IconPixmap []tray.Pixmap
+	Title      string
+	// Description may contain basic HTML markup
+	Description string
+}
+
+// ParseToolTip converts tooltip as it's sent on the bus to ToolTip.
+func ParseToolTip(raw tray.ToolTip) ToolTip {
+	tooltip := ToolTip{
+		IconName:    raw.First,
+		Title:       raw.Third,
+		Description: raw.Fourth,
+	}
+	for _, p := range raw.Second {
+		tooltip.IconPixmap = append(tooltip.IconPixmap, tray.Pixmap{
+			Width:  p.First,
+			Heigth: p.Second,
+			Data:   p.Third,
+		})
+	}
+	return tooltip
+}
+
+// ParsedToolTip returns the parsed ToolTip property.
+func (p Properties) ParsedToolTip() ToolTip {
+	return ParseToolTip(p.ToolTip)
+}
+
+// Icon returns the icon pixmap closest to size as an image: the smallest
+// one not smaller than size, or the largest one. It returns nil if the item
+// has no valid pixmaps, then IconName should be looked up in icon theme.
+func (p Properties) Icon(size int) image.Image {
+	return BestPixmap(p.IconPixmap, size)
+}
+
+// BestPixmap returns the pixmap closest to size as an image, see
+// Properties.Icon.
+func BestPixmap(pixmaps []tray.Pixmap, size int) image.Image {
+	var best *image.NRGBA
+	for _, p := range pixmaps {
+		m, err := p.Image()
+		if err != nil || m.Rect.Empty() {
+			continue
+		}
+		if best == nil {
+			best = m
+			continue
+		}
+		bestSize, mSize := best.Rect.Dx(), m.Rect.Dx()
+		switch {
+		case mSize >= size && (bestSize < size || mSize < bestSize):
+			best = m
+		case bestSize < size && mSize > bestSize:
+			best = m
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best
+}
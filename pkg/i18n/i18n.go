@@ -0,0 +1,121 @@
+// Package i18n translates menu labels and tray texts with gettext catalogs.
+//
+// Localizer holds the current Translator, Localizer.Text returns a value
+// that can be bound to menu items and tray properties:
+//
+//	loc := i18n.NewLocalizer(catalog)
+//	quit := menu.NewItem().LabelFrom(loc.Text("_Quit"))
+//	tray.SetTitleFrom(loc.Text("Music player"))
+//
+// When the user changes language call Localizer.SetTranslator, bound texts
+// are translated again and the host is notified.
+package i18n
+
+import "sync"
+
+// Translator translates message ids.
+type Translator interface {
+	// Translate returns translation of msgid or msgid itself if there is
+	// no translation.
+	Translate(msgid string) string
+}
+
+// Catalog is a Translator that maps message ids to translations. Messages
+// with context are stored under "context\x04msgid" like gettext does.
+type Catalog map[string]string
+
+func (c Catalog) Translate(msgid string) string {
+	if s, ok := c[msgid]; ok && s != "" {
+		return s
+	}
+	return msgid
+}
+
+// TranslateContext translates msgid in context, e.g. "menu" and "Open".
+func (c Catalog) TranslateContext(context, msgid string) string {
+	if s, ok := c[context+"\x04"+msgid]; ok && s != "" {
+		return s
+	}
+	return msgid
+}
+
+// Localizer switches translators at runtime. It's safe for concurrent use.
+type Localizer struct {
+	mu     sync.RWMutex
+	tr     Translator
+	nextID int
+	subs   map[int]func()
+}
+
+// NewLocalizer returns Localizer using tr, nil tr leaves messages
+// untranslated.
+func NewLocalizer(tr Translator) *Localizer {
+	return &Localizer{tr: tr, subs: make(map[int]func())}
+}
+
+// SetTranslator switches to tr and notifies everything that uses texts of
+// the localizer.
+func (l *Localizer) SetTranslator(tr Translator) {
+	l.mu.Lock()
+	l.tr = tr
+	subs := make([]func(), 0, len(l.subs))
+	for _, fn := range l.subs {
+		subs = append(subs, fn)
+	}
+	l.mu.Unlock()
+	for _, fn := range subs {
+		fn()
+	}
+}
+
+// Translate translates msgid with the current translator.
+func (l *Localizer) Translate(msgid string) string {
+	l.mu.RLock()
+	tr := l.tr
+	l.mu.RUnlock()
+	if tr == nil {
+		return msgid
+	}
+	return tr.Translate(msgid)
+}
+
+// Subscribe makes l call fn after the translator changes. It returns a
+// function that cancels the subscription.
+func (l *Localizer) Subscribe(fn func()) (cancel func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.nextID
+	l.nextID++
+	l.subs[id] = fn
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subs, id)
+	}
+}
+
+// Text returns translatable text, it implements menu.Source[string].
+func (l *Localizer) Text(msgid string) Text {
+	return Text{l: l, msgid: msgid}
+}
+
+// Text is a message translated with the current translator of Localizer.
+type Text struct {
+	l     *Localizer
+	msgid string
+}
+
+// Get returns translation.
+func (t Text) Get() string {
+	return t.l.Translate(t.msgid)
+}
+
+// Subscribe calls fn when the translator changes.
+func (t Text) Subscribe(fn func()) (cancel func()) {
+	return t.l.Subscribe(fn)
+}
+
+// SubscriptionKey implements menu.Shared, all texts of l change together.
+func (t Text) SubscriptionKey() interface{} {
+	return t.l
+}
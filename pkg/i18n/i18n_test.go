@@ -0,0 +1,150 @@
+package i18n_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/i18n"
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const po = `# Ukrainian translation
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: main.go:10
+msgid "_Quit"
+msgstr "_Вийти"
+
+msgctxt "menu"
+msgid "Open"
+msgstr "Відкрити"
+
+#, fuzzy
+msgid "Pause"
+msgstr "Пауза"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d файл"
+msgstr[1] "%d файли"
+
+msgid "Long"
+msgstr ""
+"Дуже \"довгий\"\n"
+"текст"
+`
+
+func TestParsePO(t *testing.T) {
+	assert := assert.New(t)
+	c, err := i18n.ParsePO(strings.NewReader(po))
+	require.NoError(t, err)
+	assert.Equal(i18n.Catalog{
+		"_Quit":        "_Вийти",
+		"menu\x04Open": "Відкрити",
+		"%d file":      "%d файл",
+		"Long":         "Дуже \"довгий\"\nтекст",
+	}, c)
+	assert.Equal("Відкрити", c.TranslateContext("menu", "Open"))
+	assert.Equal("Pause", c.Translate("Pause"))
+
+	_, err = i18n.ParsePO(strings.NewReader("msgid \"a\"\nmsgtxt \"b\"\n"))
+	assert.EqualError(err, `po: line 2: unknown keyword "msgtxt"`)
+}
+
+// encodeMO writes a little-endian .mo file without hash table.
+func encodeMO(messages [][2]string) []byte {
+	const header = 28
+	var strs bytes.Buffer
+	origTable := make([]uint32, 0, 2*len(messages))
+	transTable := make([]uint32, 0, 2*len(messages))
+	base := uint32(header + 16*len(messages))
+	for _, m := range messages {
+		origTable = append(origTable, uint32(len(m[0])), base+uint32(strs.Len()))
+		strs.WriteString(m[0] + "\x00")
+	}
+	for _, m := range messages {
+		transTable = append(transTable, uint32(len(m[1])), base+uint32(strs.Len()))
+		strs.WriteString(m[1] + "\x00")
+	}
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&b, le, []uint32{0x950412de, 0, uint32(len(messages)),
+		header, header + uint32(8*len(messages)), 0, 0})
+	binary.Write(&b, le, origTable)
+	binary.Write(&b, le, transTable)
+	b.Write(strs.Bytes())
+	return b.Bytes()
+}
+
+func TestFindMO(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	messages := filepath.Join(dir, "uk", "LC_MESSAGES")
+	require.NoError(t, os.MkdirAll(messages, 0o755))
+	mo := encodeMO([][2]string{
+		{"", "Content-Type: text/plain; charset=UTF-8\n"},
+		{"_Quit", "_Вийти"},
+		{"%d file\x00%d files", "%d файл\x00%d файли"},
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(messages, "player.mo"), mo, 0o644))
+
+	c, err := i18n.Find(dir, "player", "uk_UA.UTF-8")
+	require.NoError(t, err)
+	assert.Equal(i18n.Catalog{"_Quit": "_Вийти", "%d file": "%d файл"}, c)
+
+	_, err = i18n.Find(dir, "player", "de_DE")
+	assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestParseMOInvalid(t *testing.T) {
+	valid := encodeMO([][2]string{{"_Quit", "_Вийти"}})
+	withCount := func(count uint32) []byte {
+		data := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(data[8:], count)
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"short", valid[:19], "mo: file is too short"},
+		{"magic", append([]byte{0, 0, 0, 0}, valid[4:]...), "mo: bad magic number"},
+		{"huge count", withCount(0xffffffff), "mo: 4294967295 strings don't fit in the file"},
+		{"count past tables", withCount(2), "mo: string 1 is out of file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := i18n.ParseMO(bytes.NewReader(tt.data))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestLocalizerMenu(t *testing.T) {
+	assert := assert.New(t)
+	loc := i18n.NewLocalizer(nil)
+	quit := menu.NewItem().LabelFrom(loc.Text("_Quit"))
+	menu.NewMenuServer(menu.NewItem().Submenu(quit).Build())
+
+	label, _ := quit.Property("label")
+	assert.Equal("_Quit", label.Value())
+
+	loc.SetTranslator(i18n.Catalog{"_Quit": "_Вийти"})
+	label, _ = quit.Property("label")
+	assert.Equal("_Вийти", label.Value())
+
+	// Texts change together, so menus subscribe to them once.
+	var text menu.Source[string] = loc.Text("_Open")
+	assert.Implements((*menu.Shared)(nil), text)
+	assert.Equal(loc.Text("_Quit").SubscriptionKey(), loc.Text("_Open").SubscriptionKey())
+}
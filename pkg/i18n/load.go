@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadFile reads .po or .mo file.
+func LoadFile(path string) (Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c Catalog
+	switch ext := filepath.Ext(path); ext {
+	case ".po":
+		c, err = ParsePO(f)
+	case ".mo":
+		c, err = ParseMO(f)
+	default:
+		return nil, fmt.Errorf("i18n: unknown catalog format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Find loads catalog of domain for locale from dir laid out like
+// /usr/share/locale: dir/<locale>/LC_MESSAGES/<domain>.mo or .po. Locale
+// "pt_BR.UTF-8" is looked up as "pt_BR" and then "pt". It returns
+// fs.ErrNotExist if there is no catalog.
+func Find(dir, domain, locale string) (Catalog, error) {
+	for _, name := range localeNames(locale) {
+		for _, ext := range []string{".mo", ".po"} {
+			path := filepath.Join(dir, name, "LC_MESSAGES", domain+ext)
+			c, err := LoadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return c, err
+		}
+	}
+	return nil, fmt.Errorf("i18n: no %s catalog for %q in %s: %w",
+		domain, locale, dir, fs.ErrNotExist)
+}
+
+// localeNames returns names to look locale up by, most specific first.
+func localeNames(locale string) []string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	names := []string{locale}
+	if lang, _, ok := strings.Cut(locale, "_"); ok {
+		names = append(names, lang)
+	}
+	return names
+}
+
+// Locale returns locale of messages from LANGUAGE, LC_ALL, LC_MESSAGES or
+// LANG, the first one set wins. Only the first entry of LANGUAGE is used.
+func Locale() string {
+	for _, env := range []string{"LANGUAGE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			v, _, _ = strings.Cut(v, ":")
+			return v
+		}
+	}
+	return "C"
+}
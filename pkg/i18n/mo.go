@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	moMagicLittle = 0x950412de
+	moMagicBig    = 0xde120495
+)
+
+// ParseMO reads a compiled gettext .mo file. For plural messages the first
+// form is used.
+func ParseMO(r io.Reader) (Catalog, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 20 {
+		return nil, errors.New("mo: file is too short")
+	}
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case moMagicLittle:
+		order = binary.LittleEndian
+	case moMagicBig:
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("mo: bad magic number")
+	}
+	count := order.Uint32(data[8:])
+	origTable := order.Uint32(data[12:])
+	transTable := order.Uint32(data[16:])
+	// Every string has 8 bytes in each of the two tables, so count can't be
+	// larger than the file allows.
+	if uint64(count)*16 > uint64(len(data)) {
+		return nil, fmt.Errorf("mo: %d strings don't fit in the file", count)
+	}
+
+	// str returns n-th string of the table at offset.
+	str := func(table, n uint32) ([]byte, error) {
+		at := uint64(table) + uint64(n)*8
+		if at+8 > uint64(len(data)) {
+			return nil, fmt.Errorf("mo: string table entry %d is out of file", n)
+		}
+		length, offset := order.Uint32(data[at:]), order.Uint32(data[at+4:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("mo: string %d is out of file", n)
+		}
+		return data[offset : offset+length], nil
+	}
+
+	c := make(Catalog, count)
+	for n := uint32(0); n < count; n++ {
+		orig, err := str(origTable, n)
+		if err != nil {
+			return nil, err
+		}
+		trans, err := str(transTable, n)
+		if err != nil {
+			return nil, err
+		}
+		// Plural forms are separated by NUL.
+		orig, _, _ = bytes.Cut(orig, []byte{0})
+		trans, _, _ = bytes.Cut(trans, []byte{0})
+		if len(orig) == 0 || len(trans) == 0 {
+			continue
+		}
+		c[string(orig)] = string(trans)
+	}
+	return c, nil
+}
@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParsePO reads a gettext .po file. Fuzzy entries are skipped, for plural
+// messages the first form is used.
+func ParsePO(r io.Reader) (Catalog, error) {
+	c := make(Catalog)
+	var (
+		entry    poEntry
+		field    *string
+		lineNum  int
+		scanner  = bufio.NewScanner(r)
+		flush    = func() { entry.addTo(c); entry = poEntry{} }
+		keywords = map[string]func() *string{
+			"msgctxt":   func() *string { entry.hasCtxt = true; return &entry.ctxt },
+			"msgid":     func() *string { return &entry.id },
+			"msgstr":    func() *string { return &entry.str },
+			"msgstr[0]": func() *string { return &entry.str },
+		}
+	)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			// A comment starts a new entry.
+			if entry.complete {
+				flush()
+			}
+			if strings.HasPrefix(line, "#,") && strings.Contains(line, "fuzzy") {
+				entry.fuzzy = true
+			}
+			field = nil
+			continue
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return nil, fmt.Errorf("po: line %d: string without keyword", lineNum)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("po: line %d: %w", lineNum, err)
+			}
+			*field += s
+			continue
+		}
+
+		keyword, value, _ := strings.Cut(line, " ")
+		if (keyword == "msgctxt" || keyword == "msgid") && entry.complete {
+			flush()
+		}
+		switch fieldFn, ok := keywords[keyword]; {
+		case ok:
+			field = fieldFn()
+		case keyword == "msgid_plural" || strings.HasPrefix(keyword, "msgstr["):
+			// Other plural forms are not used.
+			field = new(string)
+		default:
+			return nil, fmt.Errorf("po: line %d: unknown keyword %q", lineNum, keyword)
+		}
+		if strings.HasPrefix(keyword, "msgstr") {
+			entry.complete = true
+		}
+		s, err := strconv.Unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("po: line %d: %w", lineNum, err)
+		}
+		*field += s
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return c, nil
+}
+
+type poEntry struct {
+	ctxt, id, str   string
+	hasCtxt         bool
+	fuzzy, complete bool
+}
+
+func (e poEntry) addTo(c Catalog) {
+	// Empty msgid is the header.
+	if !e.complete || e.fuzzy || e.id == "" || e.str == "" {
+		return
+	}
+	key := e.id
+	if e.hasCtxt {
+		key = e.ctxt + "\x04" + e.id
+	}
+	c[key] = e.str
+}
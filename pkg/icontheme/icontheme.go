@@ -0,0 +1,209 @@
+// Package icontheme resolves icon names to files following the freedesktop
+// Icon Theme Specification.
+//
+// https://specifications.freedesktop.org/icon-theme-spec/icon-theme-spec-latest.html
+package icontheme
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FallbackTheme is a theme every lookup falls back to.
+const FallbackTheme = "hicolor"
+
+// ErrNotFound is returned when icon cannot be found in any theme.
+var ErrNotFound = errors.New("icon not found")
+
+// Extensions lists supported icon file extensions in order of preference.
+var Extensions = []string{".png", ".svg", ".xpm"}
+
+// Theme looks up icons in an icon theme, themes it inherits and hicolor.
+//
+// It's safe to use Theme from multiple goroutines.
+type Theme struct {
+	name     string
+	baseDirs []string
+
+	mu sync.Mutex
+	// indexes caches parsed index.theme files, nil value means that the
+	// theme wasn't found
+	indexes map[indexKey]*index
+}
+
+// indexKey is a theme name and the directories it was looked for in, extra
+// directories differ between lookups.
+type indexKey struct {
+	theme string
+	dirs  string
+}
+
+// New returns Theme named name that looks for themes in baseDirs. If baseDirs
+// is empty, DefaultBaseDirs is used.
+func New(name string, baseDirs ...string) *Theme {
+	if len(baseDirs) == 0 {
+		baseDirs = DefaultBaseDirs()
+	}
+	return &Theme{
+		name:     name,
+		baseDirs: baseDirs,
+		indexes:  make(map[indexKey]*index),
+	}
+}
+
+// Name returns name of the theme.
+func (t *Theme) Name() string {
+	return t.name
+}
+
+// DefaultBaseDirs returns directories where themes are looked for as defined
+// by the spec: $HOME/.icons, $XDG_DATA_HOME/icons, $XDG_DATA_DIRS/icons and
+// /usr/share/pixmaps.
+func DefaultBaseDirs() []string {
+	var dirs []string
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".icons"))
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "icons"))
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "icons"))
+		}
+	}
+	return append(dirs, "/usr/share/pixmaps")
+}
+
+// DefaultThemeName returns icon theme name configured for GTK or KDE. Returns
+// FallbackTheme if none is configured.
+func DefaultThemeName() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	candidates := []struct{ file, section, key string }{
+		{filepath.Join(configHome, "gtk-3.0", "settings.ini"), "Settings", "gtk-icon-theme-name"},
+		{filepath.Join(configHome, "kdeglobals"), "Icons", "Theme"},
+	}
+	for _, c := range candidates {
+		f, err := os.Open(c.file)
+		if err != nil {
+			continue
+		}
+		ini, err := parseIni(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		if name := ini[c.section][c.key]; name != "" {
+			return name
+		}
+	}
+	return FallbackTheme
+}
+
+// Lookup returns path to the icon file that best matches size. Directories
+// in extraDirs, for example IconThemePath of an item, are searched first for
+// themed and then for unthemed icons.
+//
+// Returns ErrNotFound if there is no such icon.
+func (t *Theme) Lookup(name string, size int, extraDirs ...string) (string, error) {
+	if name == "" {
+		return "", ErrNotFound
+	}
+	dirs := append(append([]string{}, extraDirs...), t.baseDirs...)
+	visited := make(map[string]bool)
+	if path, ok := t.lookupTheme(t.name, name, size, dirs, visited); ok {
+		return path, nil
+	}
+	if path, ok := t.lookupTheme(FallbackTheme, name, size, dirs, visited); ok {
+		return path, nil
+	}
+	if path, ok := lookupUnthemed(name, dirs); ok {
+		return path, nil
+	}
+	return "", ErrNotFound
+}
+
+func (t *Theme) lookupTheme(
+	theme, name string,
+	size int,
+	dirs []string,
+	visited map[string]bool,
+) (string, bool) {
+	if visited[theme] {
+		return "", false
+	}
+	visited[theme] = true
+	idx := t.index(theme, dirs)
+	if idx == nil {
+		return "", false
+	}
+	if path, ok := idx.lookup(name, size, dirs); ok {
+		return path, true
+	}
+	for _, parent := range idx.inherits {
+		if path, ok := t.lookupTheme(parent, name, size, dirs, visited); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// index returns parsed index.theme of theme, it's read from the first
+// directory in dirs that has it.
+func (t *Theme) index(theme string, dirs []string) *index {
+	key := indexKey{theme: theme, dirs: strings.Join(dirs, "\x00")}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if idx, ok := t.indexes[key]; ok {
+		return idx
+	}
+	var idx *index
+	for _, dir := range dirs {
+		f, err := os.Open(filepath.Join(dir, theme, "index.theme"))
+		if err != nil {
+			continue
+		}
+		ini, err := parseIni(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		idx = newIndex(theme, ini)
+		break
+	}
+	t.indexes[key] = idx
+	return idx
+}
+
+func lookupUnthemed(name string, dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		for _, ext := range Extensions {
+			path := filepath.Join(dir, name+ext)
+			if isFile(path) {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
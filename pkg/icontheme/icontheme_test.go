@@ -0,0 +1,177 @@
+package icontheme_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/icontheme"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func writePng(t *testing.T, path string, size int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, size, size))))
+}
+
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	base := t.TempDir()
+	extra := t.TempDir()
+
+	writeFile(t, filepath.Join(base, "child", "index.theme"), `
+[Icon Theme]
+Name=Child
+Inherits=parent
+Directories=16x16/apps
+
+[16x16/apps]
+Size=16
+Type=Fixed
+`)
+	writeFile(t, filepath.Join(base, "parent", "index.theme"), `
+[Icon Theme]
+Name=Parent
+Directories=22x22/apps,48x48/apps
+
+[22x22/apps]
+Size=22
+Type=Fixed
+
+[48x48/apps]
+Size=48
+Type=Fixed
+`)
+	writeFile(t, filepath.Join(base, "hicolor", "index.theme"), `
+[Icon Theme]
+Name=Hicolor
+Directories=32x32/apps
+
+[32x32/apps]
+Size=32
+`)
+	writePng(t, filepath.Join(base, "child", "16x16", "apps", "app.png"), 16)
+	writePng(t, filepath.Join(base, "parent", "22x22", "apps", "app.png"), 22)
+	writePng(t, filepath.Join(base, "parent", "48x48", "apps", "app.png"), 48)
+	writePng(t, filepath.Join(base, "hicolor", "32x32", "apps", "fallback.png"), 32)
+	writePng(t, filepath.Join(extra, "custom.png"), 24)
+
+	theme := icontheme.New("child", base)
+
+	path, err := theme.Lookup("app", 16)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(base, "child", "16x16", "apps", "app.png"), path)
+
+	// child has app icon only in 16x16, which is closer than anything in
+	// parent, inherited themes are searched only when there is no icon
+	path, err = theme.Lookup("app", 48)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(base, "child", "16x16", "apps", "app.png"), path)
+
+	path, err = theme.Lookup("fallback", 48)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(base, "hicolor", "32x32", "apps", "fallback.png"), path)
+
+	path, err = theme.Lookup("custom", 24, extra)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(extra, "custom.png"), path)
+
+	_, err = theme.Lookup("missing", 24, extra)
+	assert.ErrorIs(err, icontheme.ErrNotFound)
+
+	img, err := theme.LoadIcon("app", 22)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 16, 16), img.Bounds())
+}
+
+func TestLookupThemeInExtraDir(t *testing.T) {
+	base := t.TempDir()
+	extra := t.TempDir()
+	writeFile(t, filepath.Join(extra, "app", "index.theme"), `
+[Icon Theme]
+Name=App
+Directories=16x16/apps
+
+[16x16/apps]
+Size=16
+`)
+	writePng(t, filepath.Join(extra, "app", "16x16", "apps", "app-icon.png"), 16)
+
+	theme := icontheme.New("app", base)
+	_, err := theme.Lookup("app-icon", 16)
+	assert.ErrorIs(t, err, icontheme.ErrNotFound)
+
+	// The theme missing from base dirs is still found in extra dirs.
+	path, err := theme.Lookup("app-icon", 16, extra)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(extra, "app", "16x16", "apps", "app-icon.png"), path)
+}
+
+func TestDecodeXPM(t *testing.T) {
+	assert := assert.New(t)
+	img, err := icontheme.DecodeXPM(strings.NewReader(`/* XPM */
+static char * test_xpm[] = {
+/* columns rows colors chars-per-pixel */
+"3 2 3 1",
+"  c None",
+". c #FF0000",
+"+ c light grey",
+/* pixels */
+" .+",
+"+. "};`))
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 3, 2), img.Bounds())
+	assert.Equal(color.NRGBA{}, img.At(0, 0))
+	assert.Equal(color.NRGBA{R: 0xff, A: 0xff}, img.At(1, 0))
+	assert.Equal(color.NRGBA{R: 0xd3, G: 0xd3, B: 0xd3, A: 0xff}, img.At(2, 0))
+	assert.Equal(color.NRGBA{R: 0xd3, G: 0xd3, B: 0xd3, A: 0xff}, img.At(0, 1))
+}
+
+func TestDecodeXPMInvalid(t *testing.T) {
+	xpm := func(lines ...string) string {
+		return "/* XPM */\nstatic char * test_xpm[] = {\n\"" +
+			strings.Join(lines, "\",\n\"") + "\"};"
+	}
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"no header", `"1 1 1 1"`, "xpm: missing XPM header"},
+		{"no values", "/* XPM */", "xpm: no values section"},
+		{"negative", xpm("-1 1 1 1", ". c #000", "."), `xpm: invalid values "-1 1 1 1"`},
+		{"huge colors", xpm("1 1 9223372036854775807 1", ". c #000", "."),
+			`xpm: values "1 1 9223372036854775807 1" exceed the data`},
+		{"huge height", xpm("1 9223372036854775807 1 1", ". c #000", "."),
+			`xpm: values "1 9223372036854775807 1 1" exceed the data`},
+		{"huge width", xpm("9223372036854775807 1 1 1", ". c #000", "."),
+			`xpm: values "9223372036854775807 1 1 1" exceed the data`},
+		{"huge chars per pixel", xpm("2 1 1 4611686018427387904", ". c #000", ".."),
+			`xpm: values "2 1 1 4611686018427387904" exceed the data`},
+		{"missing rows", xpm("1 2 1 1", ". c #000", "."), "xpm: unexpected end of data"},
+		{"short row", xpm("2 1 1 1", ". c #000", "."), "xpm: row 0 is too short"},
+		{"unknown pixel", xpm("1 1 1 1", ". c #000", "+"), `xpm: unknown pixel "+"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := icontheme.DecodeXPM(strings.NewReader(tt.src))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package icontheme
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type dirType string
+
+const (
+	dirFixed     dirType = "Fixed"
+	dirScalable  dirType = "Scalable"
+	dirThreshold dirType = "Threshold"
+)
+
+// subdir is a directory section of index.theme.
+type subdir struct {
+	path      string
+	size      int
+	scale     int
+	typ       dirType
+	minSize   int
+	maxSize   int
+	threshold int
+}
+
+// index is a parsed index.theme file.
+type index struct {
+	name     string
+	inherits []string
+	subdirs  []subdir
+}
+
+func newIndex(name string, ini map[string]map[string]string) *index {
+	idx := &index{name: name}
+	theme := ini["Icon Theme"]
+	idx.inherits = splitList(theme["Inherits"])
+	dirs := append(splitList(theme["Directories"]),
+		splitList(theme["ScaledDirectories"])...)
+	for _, dir := range dirs {
+		section, ok := ini[dir]
+		if !ok {
+			continue
+		}
+		size := atoi(section["Size"], 0)
+		if size <= 0 {
+			continue
+		}
+		sd := subdir{
+			path:      dir,
+			size:      size,
+			scale:     atoi(section["Scale"], 1),
+			typ:       dirType(section["Type"]),
+			minSize:   atoi(section["MinSize"], size),
+			maxSize:   atoi(section["MaxSize"], size),
+			threshold: atoi(section["Threshold"], 2),
+		}
+		if sd.typ == "" {
+			sd.typ = dirThreshold
+		}
+		idx.subdirs = append(idx.subdirs, sd)
+	}
+	return idx
+}
+
+// lookup implements LookupIcon from the spec for scale 1.
+func (idx *index) lookup(name string, size int, dirs []string) (string, bool) {
+	for _, sd := range idx.subdirs {
+		if !sd.matches(size) {
+			continue
+		}
+		if path, ok := idx.find(sd, name, dirs); ok {
+			return path, true
+		}
+	}
+
+	best, bestDistance := "", -1
+	for _, sd := range idx.subdirs {
+		distance := sd.distance(size)
+		if bestDistance >= 0 && distance >= bestDistance {
+			continue
+		}
+		if path, ok := idx.find(sd, name, dirs); ok {
+			best, bestDistance = path, distance
+		}
+	}
+	return best, best != ""
+}
+
+func (idx *index) find(sd subdir, name string, dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		for _, ext := range Extensions {
+			path := filepath.Join(dir, idx.name, sd.path, name+ext)
+			if isFile(path) {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matches implements DirectoryMatchesSize from the spec.
+func (sd subdir) matches(size int) bool {
+	if sd.scale != 1 {
+		return false
+	}
+	switch sd.typ {
+	case dirFixed:
+		return sd.size == size
+	case dirScalable:
+		return sd.minSize <= size && size <= sd.maxSize
+	default:
+		return sd.size-sd.threshold <= size && size <= sd.size+sd.threshold
+	}
+}
+
+// distance implements DirectorySizeDistance from the spec.
+func (sd subdir) distance(size int) int {
+	switch sd.typ {
+	case dirFixed:
+		return abs(sd.size*sd.scale - size)
+	case dirScalable:
+		if size < sd.minSize*sd.scale {
+			return sd.minSize*sd.scale - size
+		}
+		if size > sd.maxSize*sd.scale {
+			return size - sd.maxSize*sd.scale
+		}
+		return 0
+	default:
+		if size < (sd.size-sd.threshold)*sd.scale {
+			return sd.minSize*sd.scale - size
+		}
+		if size > (sd.size+sd.threshold)*sd.scale {
+			return size - sd.maxSize*sd.scale
+		}
+		return 0
+	}
+}
+
+// parseIni parses desktop entry like file into map of sections to keys.
+// Localized keys are kept as is, e.g. "Name[de]".
+func parseIni(r io.Reader) (map[string]map[string]string, error) {
+	ini := make(map[string]map[string]string)
+	var section map[string]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			name := line[1 : len(line)-1]
+			section = ini[name]
+			if section == nil {
+				section = make(map[string]string)
+				ini[name] = section
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == nil {
+			continue
+		}
+		section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return ini, scanner.Err()
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func atoi(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package icontheme
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knightpp/sni/pkg/svgicon"
+)
+
+// Load decodes icon file found by Lookup. The format is chosen by file
+// extension. SVG icons are rasterised at their own size.
+func Load(path string) (image.Image, error) {
+	return LoadSized(path, 0)
+}
+
+// LoadSized is like Load, but rasterises SVG icons at size x size. Raster
+// formats are returned as is. If size is 0, SVG is rasterised at its own size.
+func LoadSized(path string, size int) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		return png.Decode(f)
+	case ".svg":
+		icon, err := svgicon.Decode(f)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 {
+			return icon.Rasterize(icon.Size()), nil
+		}
+		return icon.Square(size), nil
+	case ".xpm":
+		return DecodeXPM(f)
+	default:
+		return nil, fmt.Errorf("unsupported icon format %q", ext)
+	}
+}
+
+// LoadIcon looks up icon and decodes it, see Lookup.
+func (t *Theme) LoadIcon(name string, size int, extraDirs ...string) (image.Image, error) {
+	path, err := t.Lookup(name, size, extraDirs...)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSized(path, size)
+}
@@ -0,0 +1,182 @@
+package icontheme
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DecodeXPM decodes XPM3 image. Colors are read from the "c" (color visual)
+// key, falling back to other keys if it's absent. Only hex colors, "None" and
+// a handful of common X11 color names are supported.
+func DecodeXPM(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := xpmStrings(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("xpm: no values section")
+	}
+
+	var width, height, ncolors, cpp int
+	_, err = fmt.Sscan(lines[0], &width, &height, &ncolors, &cpp)
+	if err != nil {
+		return nil, fmt.Errorf("xpm: invalid values %q: %w", lines[0], err)
+	}
+	if width <= 0 || height <= 0 || ncolors <= 0 || cpp <= 0 {
+		return nil, fmt.Errorf("xpm: invalid values %q", lines[0])
+	}
+	// Values come from the file, bound them by its size before any
+	// arithmetic: every color and row is a string and a row has width*cpp
+	// characters.
+	if ncolors > len(lines) || height > len(lines) || cpp > len(data) || width > len(data)/cpp {
+		return nil, fmt.Errorf("xpm: values %q exceed the data", lines[0])
+	}
+	if len(lines) < 1+ncolors+height {
+		return nil, errors.New("xpm: unexpected end of data")
+	}
+
+	palette := make(map[string]color.Color, ncolors)
+	for _, line := range lines[1 : 1+ncolors] {
+		if len(line) < cpp {
+			return nil, fmt.Errorf("xpm: invalid color %q", line)
+		}
+		c, err := xpmColor(line[cpp:])
+		if err != nil {
+			return nil, err
+		}
+		palette[line[:cpp]] = c
+	}
+
+	rows := lines[1+ncolors : 1+ncolors+height]
+	for y, line := range rows {
+		if len(line) < width*cpp {
+			return nil, fmt.Errorf("xpm: row %d is too short", y)
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y, line := range rows {
+		for x := 0; x < width; x++ {
+			key := line[x*cpp : (x+1)*cpp]
+			c, ok := palette[key]
+			if !ok {
+				return nil, fmt.Errorf("xpm: unknown pixel %q", key)
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// xpmStrings returns contents of C string literals, skipping comments.
+func xpmStrings(src string) ([]string, error) {
+	if !strings.Contains(src, "XPM") {
+		return nil, errors.New("xpm: missing XPM header")
+	}
+	var out []string
+	for i := 0; i < len(src); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("xpm: unterminated comment")
+			}
+			i += end + 3
+		case src[i] == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("xpm: unterminated string")
+			}
+			out = append(out, src[i+1:i+1+end])
+			i += end + 1
+		}
+	}
+	return out, nil
+}
+
+// xpmColor parses color definition, i.e. "c #ff0000 m black".
+func xpmColor(def string) (color.Color, error) {
+	keys := map[string]bool{"c": true, "m": true, "g": true, "g4": true, "s": true}
+	values := make(map[string]string)
+	var key string
+	for _, field := range strings.Fields(def) {
+		if keys[field] && (key == "" || values[key] != "") {
+			key = field
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("xpm: invalid color %q", def)
+		}
+		if values[key] != "" {
+			values[key] += " "
+		}
+		values[key] += field
+	}
+	for _, k := range []string{"c", "g", "g4", "m"} {
+		if v, ok := values[k]; ok {
+			return parseXColor(v)
+		}
+	}
+	return nil, fmt.Errorf("xpm: invalid color %q", def)
+}
+
+func parseXColor(s string) (color.Color, error) {
+	if strings.EqualFold(s, "none") {
+		return color.Transparent, nil
+	}
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		var digits int
+		switch len(hex) {
+		case 3, 6, 9, 12:
+			digits = len(hex) / 3
+		default:
+			return nil, fmt.Errorf("xpm: invalid color %q", s)
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(hex[i*digits:(i+1)*digits], 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("xpm: invalid color %q", s)
+			}
+			// Scale to 8 bits.
+			maxV := uint64(1)<<(4*digits) - 1
+			rgb[i] = uint8(v * 0xff / maxV)
+		}
+		return color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, nil
+	}
+	name := strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	if c, ok := xColorNames[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("xpm: unknown color name %q", s)
+}
+
+var xColorNames = map[string]color.NRGBA{
+	"black":     {0x00, 0x00, 0x00, 0xff},
+	"white":     {0xff, 0xff, 0xff, 0xff},
+	"red":       {0xff, 0x00, 0x00, 0xff},
+	"green":     {0x00, 0xff, 0x00, 0xff},
+	"blue":      {0x00, 0x00, 0xff, 0xff},
+	"yellow":    {0xff, 0xff, 0x00, 0xff},
+	"cyan":      {0x00, 0xff, 0xff, 0xff},
+	"magenta":   {0xff, 0x00, 0xff, 0xff},
+	"gray":      {0xbe, 0xbe, 0xbe, 0xff},
+	"grey":      {0xbe, 0xbe, 0xbe, 0xff},
+	"lightgray": {0xd3, 0xd3, 0xd3, 0xff},
+	"lightgrey": {0xd3, 0xd3, 0xd3, 0xff},
+	"darkgray":  {0xa9, 0xa9, 0xa9, 0xff},
+	"darkgrey":  {0xa9, 0xa9, 0xa9, 0xff},
+	"orange":    {0xff, 0xa5, 0x00, 0xff},
+	"brown":     {0xa5, 0x2a, 0x2a, 0xff},
+	"navy":      {0x00, 0x00, 0x80, 0xff},
+	"purple":    {0xa0, 0x20, 0xf0, 0xff},
+}
@@ -0,0 +1,253 @@
+package menu
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Source is a value that changes over time, e.g. a piece of application
+// state. Items can bind properties to sources, see Item.LabelFrom.
+type Source[T any] interface {
+	Get() T
+	// Subscribe makes Source call fn after the value changes. It returns a
+	// function that cancels the subscription.
+	Subscribe(fn func()) (cancel func())
+}
+
+// Value is a Source that holds a value. It's safe for concurrent use.
+type Value[T any] struct {
+	mu     sync.Mutex
+	value  T
+	nextID int
+	subs   map[int]func()
+}
+
+// NewValue returns Value holding v.
+func NewValue[T any](v T) *Value[T] {
+	return &Value[T]{value: v, subs: make(map[int]func())}
+}
+
+func (v *Value[T]) Get() T {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.value
+}
+
+// Set changes the value and notifies subscribers.
+func (v *Value[T]) Set(value T) {
+	v.mu.Lock()
+	v.value = value
+	subs := make([]func(), 0, len(v.subs))
+	for _, fn := range v.subs {
+		subs = append(subs, fn)
+	}
+	v.mu.Unlock()
+	for _, fn := range subs {
+		fn()
+	}
+}
+
+func (v *Value[T]) Subscribe(fn func()) (cancel func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id := v.nextID
+	v.nextID++
+	v.subs[id] = fn
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.subs, id)
+	}
+}
+
+// Func is a Source computed by a function, e.g.
+//
+//	item.EnabledFrom(menu.Func[bool](player.CanPause))
+//
+// Func doesn't know when its result changes: call MenuServer.Invalidate
+// after changing the state it depends on. Bindings are also recomputed
+// after every click handler.
+type Func[T any] func() T
+
+func (f Func[T]) Get() T {
+	return f()
+}
+
+func (f Func[T]) Subscribe(func()) (cancel func()) {
+	return func() {}
+}
+
+// Shared is implemented by sources that change together with other sources,
+// e.g. texts of one translation catalog. Sources with equal SubscriptionKey
+// are subscribed to once, so a change makes the server send one update
+// rather than one per source.
+type Shared interface {
+	SubscriptionKey() interface{}
+}
+
+// binding computes value of a property.
+type binding struct {
+	get       func() interface{}
+	subscribe func(fn func()) (cancel func())
+	// source identifies the source to subscribe only once: the subscription
+	// key of Shared sources or the pointer of pointer sources. It's nil if
+	// there's no such key or it can't be a map key.
+	source interface{}
+}
+
+func bind[T any](i *Item, name string, src Source[T], convert func(T) interface{}) *Item {
+	b := binding{
+		get:       func() interface{} { return convert(src.Get()) },
+		subscribe: src.Subscribe,
+	}
+	if shared, ok := src.(Shared); ok {
+		if key := shared.SubscriptionKey(); hashable(key) {
+			b.source = key
+		}
+	} else if reflect.ValueOf(src).Kind() == reflect.Pointer {
+		b.source = src
+	}
+	value := b.get()
+	i.mu.Lock()
+	if i.bindings == nil {
+		i.bindings = make(map[string]binding)
+	}
+	i.bindings[name] = b
+	i.properties[name] = dbus.MakeVariant(value)
+	subs := i.subs
+	i.mu.Unlock()
+	// The item is already served, e.g. the binding is attached from a click
+	// handler.
+	if subs != nil {
+		subs.add(b)
+	}
+	return i
+}
+
+// hashable reports whether v can be a map key. Values of comparable types
+// may still hold unhashable values in interface fields, e.g. slices, then
+// hashing panics.
+func hashable(v interface{}) (ok bool) {
+	if v == nil {
+		return false
+	}
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[interface{}]bool{v: true}
+	return true
+}
+
+func identity[T any](v T) interface{} {
+	return v
+}
+
+// LabelFrom binds label to src, see Label.
+func (i *Item) LabelFrom(src Source[string]) *Item {
+	return bind(i, "label", src, identity[string])
+}
+
+// EnabledFrom binds enabled property to src, see CanBeActivated.
+func (i *Item) EnabledFrom(src Source[bool]) *Item {
+	return bind(i, "enabled", src, identity[bool])
+}
+
+// VisibleFrom binds visible property to src, see Visible.
+func (i *Item) VisibleFrom(src Source[bool]) *Item {
+	return bind(i, "visible", src, identity[bool])
+}
+
+// IconNameFrom binds icon-name property to src, see IconName.
+func (i *Item) IconNameFrom(src Source[string]) *Item {
+	return bind(i, "icon-name", src, identity[string])
+}
+
+// ToggleStateFrom binds toggle-state property to src, see ToggleState.
+func (i *Item) ToggleStateFrom(src Source[bool]) *Item {
+	return bind(i, "toggle-state", src, func(on bool) interface{} {
+		return toggleState(on)
+	})
+}
+
+// refresh recomputes bound properties.
+func (i *Item) refresh() {
+	i.mu.RLock()
+	bindings := make(map[string]binding, len(i.bindings))
+	for name, b := range i.bindings {
+		bindings[name] = b
+	}
+	i.mu.RUnlock()
+
+	for name, b := range bindings {
+		value := b.get()
+		i.mu.Lock()
+		// the binding may be replaced meanwhile
+		if _, ok := i.bindings[name]; ok {
+			i.properties[name] = dbus.MakeVariant(value)
+		}
+		i.mu.Unlock()
+	}
+}
+
+// subscribe subscribes fn to sources of all bindings in the tree, each
+// source once. Bindings attached to the items later are subscribed too. It
+// returns a function that cancels all subscriptions.
+func (tree ItemTree) subscribe(fn func()) (cancel func()) {
+	subs := &subscriptions{fn: fn, seen: make(map[interface{}]bool)}
+	tree.root.forEach(func(i *Item) {
+		i.mu.Lock()
+		i.subs = subs
+		bindings := make([]binding, 0, len(i.bindings))
+		for _, b := range i.bindings {
+			bindings = append(bindings, b)
+		}
+		i.mu.Unlock()
+		for _, b := range bindings {
+			subs.add(b)
+		}
+	})
+	return subs.cancel
+}
+
+// subscriptions are subscriptions of a tree to sources of its bindings.
+type subscriptions struct {
+	fn func()
+
+	// mu guards fields below
+	mu sync.Mutex
+	// seen has sources that are subscribed to
+	seen    map[interface{}]bool
+	cancels []func()
+	// canceled is set once the tree is replaced
+	canceled bool
+}
+
+// add subscribes to the source of b unless it's already subscribed to.
+func (s *subscriptions) add(b binding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canceled {
+		return
+	}
+	if b.source != nil {
+		if s.seen[b.source] {
+			return
+		}
+		s.seen[b.source] = true
+	}
+	s.cancels = append(s.cancels, b.subscribe(s.fn))
+}
+
+func (s *subscriptions) cancel() {
+	s.mu.Lock()
+	cancels := s.cancels
+	s.cancels, s.canceled = nil, true
+	s.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
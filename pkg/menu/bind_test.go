@@ -0,0 +1,109 @@
+package menu_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/knightpp/sni/generated/d_bus_menu"
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindings(t *testing.T) {
+	assert := assert.New(t)
+	status := menu.NewValue("Stopped")
+	playing := false
+	play := menu.NewItem().Label("Play").
+		EnabledFrom(menu.Func[bool](func() bool { return !playing }))
+	play.OnClick(func() { playing = true })
+	statusItem := menu.NewItem().LabelFrom(status)
+	server := menu.NewMenuServer(menu.NewItem().Submenu(statusItem, play).Build())
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	status.Set("Playing")
+	require.Len(t, signals, 1)
+	assert.Equal(dbus.MakeVariant("Playing"), prop(t, statusItem, "label"))
+	updated := signals[0].(*d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignal).Body.UpdatedProps
+	assert.Equal(statusItem.ID(), updated[0].V0)
+
+	// Func bindings are recomputed after clicks.
+	assert.Nil(server.Event(play.ID(), "clicked", dbus.MakeVariant(""), 0))
+	require.Len(t, signals, 2)
+	assert.Equal(dbus.MakeVariant(false), prop(t, play, "enabled"))
+
+	// Replaced tree doesn't listen to old sources.
+	server.SetTree(menu.NewItem().Submenu(menu.NewItem().Label("Empty")).Build())
+	signals = nil
+	status.Set("Paused")
+	assert.Empty(signals)
+}
+
+func TestBindingFromHandler(t *testing.T) {
+	status := menu.NewValue("Stopped")
+	item := menu.NewItem().Label("Play")
+	item.OnClick(func() { item.LabelFrom(status) })
+	server := menu.NewMenuServer(menu.NewItem().Submenu(item).Build())
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	assert.Nil(t, server.Event(item.ID(), "clicked", dbus.MakeVariant(""), 0))
+	require.Len(t, signals, 1)
+
+	// The binding attached by the handler follows its source.
+	status.Set("Playing")
+	require.Len(t, signals, 2)
+	assert.Equal(t, dbus.MakeVariant("Playing"), prop(t, item, "label"))
+}
+
+// sharedText is a source sharing change notifications with other texts of
+// the same catalog.
+type sharedText struct {
+	catalog *menu.Value[map[string]string]
+	msgid   string
+}
+
+func (s sharedText) Get() string                         { return s.catalog.Get()[s.msgid] }
+func (s sharedText) Subscribe(fn func()) (cancel func()) { return s.catalog.Subscribe(fn) }
+func (s sharedText) SubscriptionKey() interface{}        { return s.catalog }
+
+func TestSharedSources(t *testing.T) {
+	catalog := menu.NewValue(map[string]string{"open": "Open", "quit": "Quit"})
+	open := menu.NewItem().LabelFrom(sharedText{catalog, "open"})
+	quit := menu.NewItem().LabelFrom(sharedText{catalog, "quit"})
+	server := menu.NewMenuServer(menu.NewItem().Submenu(open, quit).Build())
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	catalog.Set(map[string]string{"open": "Відкрити", "quit": "Вийти"})
+	require.Len(t, signals, 1)
+	updated := signals[0].(*d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignal).Body.UpdatedProps
+	assert.Len(t, updated, 2)
+}
+
+// wrappedSource is comparable, but hashing it panics if it wraps a slice.
+type wrappedSource struct {
+	value interface{}
+}
+
+func (s wrappedSource) Get() string                         { return fmt.Sprint(s.value) }
+func (s wrappedSource) Subscribe(fn func()) (cancel func()) { return func() {} }
+
+// sliceKeyed is a Shared source with an unhashable subscription key.
+type sliceKeyed struct {
+	wrappedSource
+}
+
+func (s sliceKeyed) SubscriptionKey() interface{} { return []string{"key"} }
+
+func TestUnhashableSources(t *testing.T) {
+	tree := menu.NewItem().Submenu(
+		menu.NewItem().LabelFrom(wrappedSource{[]string{"a"}}),
+		menu.NewItem().LabelFrom(sliceKeyed{wrappedSource{[]string{"b"}}}),
+	).Build()
+	assert.NotPanics(t, func() { menu.NewMenuServer(tree) })
+	assert.Equal(t, dbus.MakeVariant("[a]"), prop(t, tree.Root().Children()[0], "label"))
+}
@@ -0,0 +1,435 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/knightpp/sni/generated/d_bus"
+	"github.com/knightpp/sni/generated/d_bus_menu"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// RemoteItem is an item of a menu served by another process, see Client.
+// Fields have values the spec defines for missing properties.
+type RemoteItem struct {
+	ID int32
+	// Type is "standard" or "separator"
+	Type       string
+	Label      string
+	Enabled    bool
+	Visible    bool
+	IconName   string
+	IconData   []byte
+	ToggleType ToggleType
+	// ToggleState is 0 for off, 1 for on and -1 for indeterminate
+	ToggleState     int32
+	Shortcut        Shortcut
+	ChildrenDisplay string
+	Disposition     Disposition
+	// Properties are all properties as sent by the server
+	Properties map[string]dbus.Variant
+	Children   []*RemoteItem
+}
+
+// IsSeparator reports whether the item is a separator.
+func (r *RemoteItem) IsSeparator() bool {
+	return r.Type == "separator"
+}
+
+// HasSubmenu reports whether the item opens a submenu.
+func (r *RemoteItem) HasSubmenu() bool {
+	return r.ChildrenDisplay == "submenu" || len(r.Children) > 0
+}
+
+// Find returns item with id among the item and its descendants.
+func (r *RemoteItem) Find(id int32) (*RemoteItem, bool) {
+	if r.ID == id {
+		return r, true
+	}
+	for _, child := range r.Children {
+		if found, ok := child.Find(id); ok {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
+// FindLabel returns descendant item by labels of items on the way to it,
+// e.g. "File", "Quit". Labels are compared with mnemonics stripped.
+func (r *RemoteItem) FindLabel(path ...string) (*RemoteItem, bool) {
+	item := r
+	for _, label := range path {
+		var next *RemoteItem
+		for _, child := range item.Children {
+			if !child.IsSeparator() && StripMnemonic(child.Label) == label {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil, false
+		}
+		item = next
+	}
+	return item, true
+}
+
+func (r *RemoteItem) clone() *RemoteItem {
+	c := *r
+	c.Properties = make(map[string]dbus.Variant, len(r.Properties))
+	for k, v := range r.Properties {
+		c.Properties[k] = v
+	}
+	c.Children = make([]*RemoteItem, len(r.Children))
+	for n, child := range r.Children {
+		c.Children[n] = child.clone()
+	}
+	return &c
+}
+
+// setProperties replaces properties and decodes them into fields.
+func (r *RemoteItem) setProperties(props map[string]dbus.Variant) {
+	id, children := r.ID, r.Children
+	*r = RemoteItem{
+		ID:          id,
+		Type:        "standard",
+		Enabled:     true,
+		Visible:     true,
+		ToggleState: -1,
+		Disposition: DispositionNormal,
+		Properties:  props,
+		Children:    children,
+	}
+	decodeString(props, "type", &r.Type)
+	decodeString(props, "label", &r.Label)
+	decodeBool(props, "enabled", &r.Enabled)
+	decodeBool(props, "visible", &r.Visible)
+	decodeString(props, "icon-name", &r.IconName)
+	if v, ok := props["icon-data"].Value().([]byte); ok {
+		r.IconData = v
+	}
+	var toggleType string
+	decodeString(props, "toggle-type", &toggleType)
+	r.ToggleType = ToggleType(toggleType)
+	if v, ok := props["toggle-state"]; ok {
+		rv := reflect.ValueOf(v.Value())
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			r.ToggleState = int32(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			r.ToggleState = int32(rv.Uint())
+		}
+	}
+	if v, ok := props["shortcut"].Value().([][]string); ok {
+		r.Shortcut = v
+	}
+	decodeString(props, "children-display", &r.ChildrenDisplay)
+	var disposition string
+	if decodeString(props, "disposition", &disposition) {
+		r.Disposition = Disposition(disposition)
+	}
+}
+
+// decodeString stores property name into dst if it's a string, e.g. a
+// ToggleType of an in-process server.
+func decodeString(props map[string]dbus.Variant, name string, dst *string) bool {
+	v, ok := props[name]
+	if !ok {
+		return false
+	}
+	rv := reflect.ValueOf(v.Value())
+	if rv.Kind() != reflect.String {
+		return false
+	}
+	*dst = rv.String()
+	return true
+}
+
+func decodeBool(props map[string]dbus.Variant, name string, dst *bool) {
+	if v, ok := props[name].Value().(bool); ok {
+		*dst = v
+	}
+}
+
+// DecodeLayout converts layout returned by GetLayout or ItemTree.ToLayout
+// to items.
+func DecodeLayout(layout Layout) (*RemoteItem, error) {
+	item := &RemoteItem{ID: layout.V0}
+	for _, v := range layout.V2 {
+		var child Layout
+		if err := dbus.Store([]interface{}{v.Value()}, &child); err != nil {
+			return nil, fmt.Errorf("item %d: child: %w", layout.V0, err)
+		}
+		decoded, err := DecodeLayout(child)
+		if err != nil {
+			return nil, err
+		}
+		item.Children = append(item.Children, decoded)
+	}
+	item.setProperties(layout.V1)
+	return item, nil
+}
+
+// Client shows menu served over D-Bus by another process, it's the host
+// side of MenuServer. The layout is fetched by Start and kept in sync with
+// the server until Close.
+type Client struct {
+	conn    *dbus.Conn
+	service string
+	path    dbus.ObjectPath
+	proxy   *d_bus_menu.Dbusmenu
+	// changed is notified after the menu changes
+	changed chan struct{}
+	signals chan *dbus.Signal
+	done    chan struct{}
+
+	// mu guards fields below
+	mu       sync.RWMutex
+	owner    string
+	root     *RemoteItem
+	revision uint32
+}
+
+// NewClient returns Client for menu at path of service, e.g. Menu property
+// of a StatusNotifierItem.
+func NewClient(conn *dbus.Conn, service string, path dbus.ObjectPath) *Client {
+	return &Client{
+		conn:    conn,
+		service: service,
+		path:    path,
+		proxy:   d_bus_menu.NewDbusmenu(conn.Object(service, path)),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Start fetches the layout and starts listening to changes of the menu.
+func (c *Client) Start(ctx context.Context) error {
+	bus := d_bus.NewDBus(c.conn.BusObject())
+	owner, err := bus.GetNameOwner(ctx, c.service)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.owner = owner
+	c.mu.Unlock()
+	if err := c.conn.AddMatchSignal(
+		dbus.WithMatchSender(owner),
+		dbus.WithMatchObjectPath(c.path),
+		dbus.WithMatchInterface(d_bus_menu.InterfaceDbusmenu),
+	); err != nil {
+		return err
+	}
+	c.signals = make(chan *dbus.Signal, 16)
+	c.done = make(chan struct{})
+	c.conn.Signal(c.signals)
+	go c.listen(c.signals)
+	return c.Refresh(ctx)
+}
+
+// Close stops listening to changes.
+func (c *Client) Close() error {
+	if c.signals == nil {
+		return nil
+	}
+	signals := c.signals
+	c.signals = nil
+	if !c.conn.Connected() {
+		<-c.done
+		return nil
+	}
+	c.conn.RemoveSignal(signals)
+	close(signals)
+	<-c.done
+	c.mu.RLock()
+	owner := c.owner
+	c.mu.RUnlock()
+	return c.conn.RemoveMatchSignal(
+		dbus.WithMatchSender(owner),
+		dbus.WithMatchObjectPath(c.path),
+		dbus.WithMatchInterface(d_bus_menu.InterfaceDbusmenu),
+	)
+}
+
+// Refresh fetches the whole layout.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, 0)
+}
+
+// refresh fetches layout of item id and replaces it.
+func (c *Client) refresh(ctx context.Context, id int32) error {
+	revision, layout, err := c.proxy.GetLayout(ctx, id, -1, nil)
+	if err != nil {
+		return err
+	}
+	item, err := DecodeLayout(layout)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	switch {
+	case c.root == nil && id != 0:
+		c.mu.Unlock()
+		return c.refresh(ctx, 0)
+	case c.root == nil || id == c.root.ID:
+		c.root = item
+	default:
+		old, ok := c.root.Find(id)
+		if !ok {
+			// The item was removed by a later change of its parent.
+			c.mu.Unlock()
+			return c.refresh(ctx, 0)
+		}
+		*old = *item
+	}
+	c.revision = revision
+	c.notify()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Root returns a copy of the menu, it's nil before Start.
+func (c *Client) Root() *RemoteItem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.root == nil {
+		return nil
+	}
+	return c.root.clone()
+}
+
+// Item returns a copy of item id.
+func (c *Client) Item(id int32) (*RemoteItem, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.root == nil {
+		return nil, false
+	}
+	item, ok := c.root.Find(id)
+	if !ok {
+		return nil, false
+	}
+	return item.clone(), true
+}
+
+// Revision returns layout revision reported by the server.
+func (c *Client) Revision() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.revision
+}
+
+// Changed receives a value after the menu changes. Changes that come while
+// nobody receives are merged into one.
+func (c *Client) Changed() <-chan struct{} {
+	return c.changed
+}
+
+// Event sends event eventId such as "clicked", "hovered", "opened" or
+// "closed" to item id.
+func (c *Client) Event(ctx context.Context, id int32, eventId string, data dbus.Variant, timestamp uint32) error {
+	return c.proxy.Event(ctx, id, eventId, data, timestamp)
+}
+
+// Click sends "clicked" event to item id.
+func (c *Client) Click(ctx context.Context, id int32) error {
+	return c.Event(ctx, id, "clicked", dbus.MakeVariant(""), 0)
+}
+
+// AboutToShow tells the server that submenu of item id is going to be
+// shown and fetches its layout if the server updated it.
+func (c *Client) AboutToShow(ctx context.Context, id int32) error {
+	needUpdate, err := c.proxy.AboutToShow(ctx, id)
+	if err != nil {
+		return err
+	}
+	if needUpdate {
+		return c.refresh(ctx, id)
+	}
+	return nil
+}
+
+func (c *Client) listen(signals chan *dbus.Signal) {
+	defer close(c.done)
+	for sig := range signals {
+		c.mu.RLock()
+		owner := c.owner
+		c.mu.RUnlock()
+		if sig.Sender != owner || sig.Path != c.path {
+			continue
+		}
+		// Bodies are decoded with Store, LookupSignal doesn't convert
+		// structs that come over the wire.
+		switch sig.Name {
+		case d_bus_menu.InterfaceDbusmenu + ".LayoutUpdated":
+			var body d_bus_menu.Dbusmenu_LayoutUpdatedSignalBody
+			if dbus.Store(sig.Body, &body.Revision, &body.Parent) != nil {
+				continue
+			}
+			if err := c.refresh(context.Background(), body.Parent); err != nil {
+				log.Printf("menu %s%s: %v", c.service, c.path, err)
+			}
+		case d_bus_menu.InterfaceDbusmenu + ".ItemsPropertiesUpdated":
+			var body d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignalBody
+			if dbus.Store(sig.Body, &body.UpdatedProps, &body.RemovedProps) != nil {
+				continue
+			}
+			c.updateProperties(&body)
+		}
+	}
+}
+
+func (c *Client) updateProperties(body *d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignalBody) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.root == nil {
+		return
+	}
+	props := make(map[int32]map[string]dbus.Variant)
+	get := func(id int32) (map[string]dbus.Variant, bool) {
+		if p, ok := props[id]; ok {
+			return p, true
+		}
+		item, ok := c.root.Find(id)
+		if !ok {
+			return nil, false
+		}
+		p := make(map[string]dbus.Variant, len(item.Properties))
+		for k, v := range item.Properties {
+			p[k] = v
+		}
+		props[id] = p
+		return p, true
+	}
+	for _, updated := range body.UpdatedProps {
+		if p, ok := get(updated.V0); ok {
+			for k, v := range updated.V1 {
+				p[k] = v
+			}
+		}
+	}
+	for _, removed := range body.RemovedProps {
+		if p, ok := get(removed.V0); ok {
+			for _, k := range removed.V1 {
+				delete(p, k)
+			}
+		}
+	}
+	for id, p := range props {
+		item, _ := c.root.Find(id)
+		item.setProperties(p)
+	}
+	if len(props) > 0 {
+		c.notify()
+	}
+}
@@ -0,0 +1,99 @@
+package menu_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitChanged(t *testing.T, c *menu.Client) {
+	t.Helper()
+	select {
+	case <-c.Changed():
+	case <-time.After(2 * time.Second):
+		t.Fatal("menu didn't change")
+	}
+}
+
+func TestClient(t *testing.T) {
+	assert := assert.New(t)
+	address := bustest.Start(t)
+
+	clicked := make(chan struct{}, 1)
+	dark := menu.NewValue(false)
+	tree := func(files ...string) menu.ItemTree {
+		var recent []*menu.Item
+		for _, file := range files {
+			recent = append(recent, menu.NewItem().Key(file).LiteralLabel(file))
+		}
+		return menu.NewItem().Submenu(
+			menu.NewItem().Key("recent").Label("_Recent").Submenu(recent...),
+			menu.NewItem().Key("sep").Separator(true),
+			menu.NewItem().Key("dark").Label("Dark").
+				ToggleType(menu.ToggleTypeCheckmark).ToggleStateFrom(dark),
+			menu.NewItem().Key("quit").Label("Quit").IconName("application-exit").
+				Shortcut(menu.MustParseShortcut("Ctrl+Q")).
+				OnClick(func() { clicked <- struct{}{} }),
+		).Build()
+	}
+	serverConn := bustest.Connect(t, address)
+	server := menu.NewMenuServer(tree("a.txt"))
+	require.NoError(t, server.Export(serverConn, "/MenuBar"))
+
+	ctx := context.Background()
+	client := menu.NewClient(bustest.Connect(t, address), serverConn.Names()[0], "/MenuBar")
+	require.NoError(t, client.Start(ctx))
+	defer client.Close()
+	<-client.Changed()
+
+	root := client.Root()
+	require.Len(t, root.Children, 4)
+	recent, sep, darkItem, quit := root.Children[0], root.Children[1], root.Children[2], root.Children[3]
+	assert.Equal("_Recent", recent.Label)
+	assert.True(recent.HasSubmenu())
+	require.Len(t, recent.Children, 1)
+	assert.Equal("a.txt", recent.Children[0].Label)
+	assert.True(sep.IsSeparator())
+	assert.Equal(menu.ToggleTypeCheckmark, darkItem.ToggleType)
+	assert.Equal(int32(0), darkItem.ToggleState)
+	assert.True(quit.Enabled)
+	assert.True(quit.Visible)
+	assert.Equal("application-exit", quit.IconName)
+	assert.Equal(menu.MustParseShortcut("Ctrl+Q"), quit.Shortcut)
+	assert.Equal(int32(-1), quit.ToggleState)
+	found, ok := root.FindLabel("Recent", "a.txt")
+	require.True(t, ok)
+	assert.Equal(recent.Children[0], found)
+	_, ok = root.FindLabel("Recent", "b.txt")
+	assert.False(ok)
+
+	dark.Set(true)
+	waitChanged(t, client)
+	item, ok := client.Item(darkItem.ID)
+	require.True(t, ok)
+	assert.Equal(int32(1), item.ToggleState)
+
+	server.SetTree(tree("b.txt", "a.txt"))
+	waitChanged(t, client)
+	labels := []string{}
+	for _, child := range client.Root().Children[0].Children {
+		labels = append(labels, child.Label)
+	}
+	assert.Equal([]string{"b.txt", "a.txt"}, labels)
+	assert.Equal(uint32(1), client.Revision())
+
+	require.NoError(t, client.AboutToShow(ctx, recent.ID))
+	require.NoError(t, client.Click(ctx, quit.ID))
+	select {
+	case <-clicked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("item wasn't clicked")
+	}
+	assert.Error(client.Click(ctx, 42))
+}
@@ -0,0 +1,12 @@
+package menu
+
+import "github.com/knightpp/sni/generated/d_bus_menu"
+
+// CaptureSignals makes m append emitted signals to signals instead of
+// sending them.
+func CaptureSignals(m *MenuServer, signals *[]d_bus_menu.Signal) {
+	m.emit = func(s d_bus_menu.Signal) error {
+		*signals = append(*signals, s)
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package menu
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+
+	"github.com/knightpp/sni/pkg/svgicon"
+
+	"github.com/godbus/dbus/v5"
+	xdraw "golang.org/x/image/draw"
+)
+
+// DefaultIconSize is a size of menu icons used by most hosts.
+const DefaultIconSize = 16
+
+// Theme is a color scheme of the host's menu.
+type Theme int
+
+const (
+	// ThemeLight is a light background with dark text.
+	ThemeLight Theme = iota
+	// ThemeDark is a dark background with light text.
+	ThemeDark
+)
+
+// Icon PNG encodes img and sets it as icon-data.
+func (i *Item) Icon(img image.Image) *Item {
+	return i.IconData(encodePNG(img))
+}
+
+// IconSized scales img to fit size x size and sets it as icon-data. Aspect
+// ratio is preserved. It panics if size isn't positive.
+//
+// Note: see DefaultIconSize
+func (i *Item) IconSized(img image.Image, size int) *Item {
+	if size <= 0 {
+		panic(fmt.Sprintf("menu: IconSized: size must be positive, got %d", size))
+	}
+	return i.IconData(encodePNG(scaleImage(img, size)))
+}
+
+// ThemedIcon sets icon-data that depends on the host's theme, see
+// MenuServer.SetThemeFunc. light is used on light themes, dark on dark ones.
+// If size is greater than zero, images are scaled to fit size x size.
+func (i *Item) ThemedIcon(light, dark image.Image, size int) *Item {
+	if size > 0 {
+		light, dark = scaleImage(light, size), scaleImage(dark, size)
+	}
+	lightData, darkData := encodePNG(light), encodePNG(dark)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.properties["icon-data"] = dbus.MakeVariant(lightData)
+	i.darkIconData = darkData
+	return i
+}
+
+// IconSVG rasterises icon at size x size and sets it as icon-data.
+func (i *Item) IconSVG(icon *svgicon.Icon, size int) *Item {
+	return i.IconData(encodePNG(icon.Square(size)))
+}
+
+// encodePNG returns PNG encoded img as expected by icon-data property.
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	// Writing to bytes.Buffer can't fail, encoder fails only for empty
+	// images.
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// scaleImage scales img to fit size x size preserving aspect ratio. Images
+// that already fit are returned as is. Sides are at least 1 pixel, so a
+// thin image doesn't become empty. size must be positive.
+func scaleImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return img
+	}
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = b.Dy() * size / b.Dx()
+	} else {
+		w = b.Dx() * size / b.Dy()
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
@@ -0,0 +1,93 @@
+package menu_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// iconData decodes icon-data of the first item of layout.
+func iconData(t *testing.T, layout menu.Layout) image.Image {
+	t.Helper()
+	require.Len(t, layout.V2, 1)
+	child := layout.V2[0].Value().(menu.Layout)
+	data, ok := child.V1["icon-data"]
+	require.True(t, ok, "icon-data is not set")
+	img, err := png.Decode(bytes.NewReader(data.Value().([]byte)))
+	require.NoError(t, err)
+	return img
+}
+
+func TestIcon(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	tests := []struct {
+		name string
+		item *menu.Item
+		size image.Point
+	}{
+		{"Icon", menu.NewItem().Icon(solidImage(40, 20, red)), image.Pt(40, 20)},
+		{"IconSized", menu.NewItem().IconSized(solidImage(40, 20, red), 16), image.Pt(16, 8)},
+		{"IconSized fits", menu.NewItem().IconSized(solidImage(10, 10, red), 16), image.Pt(10, 10)},
+		{"IconSized thin", menu.NewItem().IconSized(solidImage(1000, 1, red), 16), image.Pt(16, 1)},
+		{"IconSized tall", menu.NewItem().IconSized(solidImage(1, 1000, red), 16), image.Pt(1, 16)},
+	}
+	for _, tt := range tests {
+		img := iconData(t, menu.NewItem().Submenu(tt.item).Build().ToLayout())
+		assert.Equal(t, tt.size, img.Bounds().Size(), tt.name)
+		r, g, b, a := img.At(img.Bounds().Dx()/2, img.Bounds().Dy()/2).RGBA()
+		assert.Equal(t, [4]uint32{0xffff, 0, 0, 0xffff}, [4]uint32{r, g, b, a}, tt.name)
+	}
+}
+
+func TestIconSizedInvalidSize(t *testing.T) {
+	assert.Panics(t, func() { menu.NewItem().IconSized(solidImage(4, 4, color.Black), 0) })
+}
+
+func TestIconName(t *testing.T) {
+	layout := menu.NewItem().Submenu(menu.NewItem().IconName("document-open")).Build().ToLayout()
+	require.Len(t, layout.V2, 1)
+	assert.Equal(t, dbus.MakeVariant("document-open"),
+		layout.V2[0].Value().(menu.Layout).V1["icon-name"])
+}
+
+func TestThemedIcon(t *testing.T) {
+	light := solidImage(32, 32, color.Black)
+	dark := solidImage(32, 32, color.White)
+	server := menu.NewMenuServer(menu.NewItem().Submenu(
+		menu.NewItem().ThemedIcon(light, dark, 16),
+	).Build())
+
+	gray := func() uint32 {
+		_, layout, err := server.GetLayout(0, -1, nil)
+		require.Nil(t, err)
+		img := iconData(t, layout)
+		assert.Equal(t, image.Pt(16, 16), img.Bounds().Size())
+		r, _, _, _ := img.At(8, 8).RGBA()
+		return r
+	}
+
+	// The light variant is used by default.
+	assert.Equal(t, uint32(0), gray())
+
+	theme := menu.ThemeDark
+	server.SetThemeFunc(func() menu.Theme { return theme })
+	assert.Equal(t, uint32(0xffff), gray())
+
+	theme = menu.ThemeLight
+	assert.Equal(t, uint32(0), gray())
+}
@@ -1,6 +1,8 @@
 package menu
 
 import (
+	"sync"
+
 	"github.com/godbus/dbus/v5"
 )
 
@@ -23,33 +25,92 @@ type ItemTree struct {
 	root *Item
 }
 
+// Root returns root item of the tree.
+func (tree ItemTree) Root() *Item {
+	return tree.root
+}
+
 func (tree ItemTree) ToLayout() Layout {
-	return tree.root.toLayout()
+	return tree.root.toLayout(ThemeLight, -1, nil)
 }
 
-func (i *Item) toLayout() Layout {
+// toLayout returns layout of the item with depth levels of children, -1
+// means all of them. Only propertyNames are included unless it's empty.
+func (i *Item) toLayout(theme Theme, depth int32, propertyNames []string) Layout {
 	var layout Layout
-	layout.V0 = i.id
-	layout.V1 = i.properties
-	for _, child := range i.children {
+	layout.V0 = i.ID()
+	layout.V1 = filterProperties(i.snapshot(theme), propertyNames)
+	if depth == 0 {
+		return layout
+	}
+	for _, child := range i.Children() {
 		layout.V2 = append(layout.V2,
-			dbus.MakeVariant(child.toLayout()))
+			dbus.MakeVariant(child.toLayout(theme, depth-1, propertyNames)))
 	}
 	return layout
 }
 
+func filterProperties(props map[string]dbus.Variant, names []string) map[string]dbus.Variant {
+	if len(names) == 0 {
+		return props
+	}
+	filtered := make(map[string]dbus.Variant, len(names))
+	for _, name := range names {
+		if v, ok := props[name]; ok {
+			filtered[name] = v
+		}
+	}
+	return filtered
+}
+
+// snapshot returns a copy of properties as they should be seen by the host.
+func (i *Item) snapshot(theme Theme) map[string]dbus.Variant {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	props := make(map[string]dbus.Variant, len(i.properties))
+	for k, v := range i.properties {
+		props[k] = v
+	}
+	if theme == ThemeDark && i.darkIconData != nil {
+		props["icon-data"] = dbus.MakeVariant(i.darkIconData)
+	}
+	return props
+}
+
 func (item *Item) forEach(fn func(*Item)) {
-	fn(item)
-	for _, child := range item.children {
-		child.forEach(fn)
+	item.forEachUntil(func(i *Item) bool {
+		fn(i)
+		return true
+	})
+}
+
+// forEachUntil is like forEach, but doesn't visit children of items for
+// which fn returns false.
+func (item *Item) forEachUntil(fn func(*Item) bool) {
+	if !fn(item) {
+		return
+	}
+	for _, child := range item.Children() {
+		child.forEachUntil(fn)
 	}
 }
 
+// Item is a menu item. It's safe to change items from click handlers while
+// the menu is served, see MenuServer.
 type Item struct {
+	// mu guards fields below, id is assigned by Build and MenuServer.SetTree
+	mu         sync.RWMutex
 	id         int32
-	onClick    func()
+	key        string
+	onClick    func() error
 	children   []*Item
 	properties map[string]dbus.Variant
+	// bindings compute properties from sources, see LabelFrom
+	bindings map[string]binding
+	// subs subscribes bindings of a served item, see ItemTree.subscribe
+	subs *subscriptions
+	// darkIconData replaces icon-data on dark themes
+	darkIconData []byte
 }
 
 func NewItem() *Item {
@@ -58,81 +119,161 @@ func NewItem() *Item {
 }
 
 func (i *Item) Build() ItemTree {
-	i.build(0)
+	var id int32
+	i.build(&id)
 	return ItemTree{root: i}
 }
 
-func (i *Item) build(id int32) {
-	i.id = id
-	for _, child := range i.children {
-		id += 1
-		child.build(id)
+// build assigns ids in depth-first order, so nested items don't collide with
+// their parent's siblings.
+func (i *Item) build(next *int32) {
+	i.setID(*next)
+	*next++
+	for _, child := range i.Children() {
+		child.build(next)
 	}
 }
 
+// ID returns id of the item assigned by Build.
+func (i *Item) ID() int32 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.id
+}
+
+func (i *Item) setID(id int32) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.id = id
+}
+
+// Key sets a key that identifies the item among its siblings. Keys let
+// MenuServer.SetTree match items of the new tree to the old ones when items
+// are added, removed or reordered. Items without keys are matched by
+// position among other items without keys.
+func (i *Item) Key(key string) *Item {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.key = key
+	return i
+}
+
+func (i *Item) getKey() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.key
+}
+
+// Children returns submenu items.
+func (i *Item) Children() []*Item {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.children
+}
+
+// Property returns value of the dbusmenu property name, e.g. "label".
+func (i *Item) Property(name string) (dbus.Variant, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	v, ok := i.properties[name]
+	return v, ok
+}
+
+func (i *Item) setProperty(name string, value interface{}) *Item {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.properties[name] = dbus.MakeVariant(value)
+	delete(i.bindings, name)
+	return i
+}
+
 func (i *Item) OnClick(fn func()) *Item {
+	return i.OnClickErr(func() error {
+		fn()
+		return nil
+	})
+}
+
+// OnClickErr sets click handler that may fail. The error is returned to the
+// host as D-Bus error reply and passed to the error reporter, see
+// MenuServer.SetErrorReporter.
+func (i *Item) OnClickErr(fn func() error) *Item {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	i.onClick = fn
 	return i
 }
 
+func (i *Item) clickHandler() func() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.onClick
+}
+
 func (i *Item) Separator(b bool) *Item {
 	if b {
-		i.properties["type"] = dbus.MakeVariant("separator")
+		i.setProperty("type", "separator")
 	}
 	return i
 }
 
 func (i *Item) Label(label string) *Item {
-	i.properties["label"] = dbus.MakeVariant(label)
-	return i
+	return i.setProperty("label", label)
+}
+
+// LiteralLabel sets label with underscores escaped, so it's displayed as is
+// without an access key. See Mnemonic.
+func (i *Item) LiteralLabel(label string) *Item {
+	return i.Label(EscapeMnemonic(label))
 }
 
 func (i *Item) CanBeActivated(b bool) *Item {
-	i.properties["enabled"] = dbus.MakeVariant(b)
-	return i
+	return i.setProperty("enabled", b)
 }
 
 func (i *Item) Visible(b bool) *Item {
-	i.properties["visible"] = dbus.MakeVariant(b)
-	return i
+	return i.setProperty("visible", b)
 }
 
 func (i *Item) IconName(iconName string) *Item {
-	i.properties["icon-name"] = dbus.MakeVariant(iconName)
-	return i
+	return i.setProperty("icon-name", iconName)
 }
 
 func (i *Item) IconData(data []byte) *Item {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	i.properties["icon-data"] = dbus.MakeVariant(data)
+	i.darkIconData = nil
 	return i
 }
 
 func (i *Item) Shortcut(shortcut [][]string) *Item {
-	i.properties["shortcut"] = dbus.MakeVariant(shortcut)
-	return i
+	return i.setProperty("shortcut", shortcut)
 }
 
 func (i *Item) ToggleType(tt ToggleType) *Item {
-	i.properties["toggle-type"] = dbus.MakeVariant(tt)
-	return i
+	return i.setProperty("toggle-type", tt)
 }
 
 func (i *Item) ToggleState(onoff bool) *Item {
-	num := uint32(0)
+	return i.setProperty("toggle-state", toggleState(onoff))
+}
+
+func toggleState(onoff bool) uint32 {
 	if onoff {
-		num = 1
+		return 1
 	}
-	i.properties["toggle-state"] = dbus.MakeVariant(num)
-	return i
+	return 0
 }
 
 func (i *Item) Submenu(children ...*Item) *Item {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	i.properties["children-display"] = dbus.MakeVariant("submenu")
 	i.children = children
 	return i
 }
 
 func (i *Item) Disposition(d Disposition) *Item {
-	i.properties["disposition"] = dbus.MakeVariant(d)
-	return i
+	return i.setProperty("disposition", d)
 }
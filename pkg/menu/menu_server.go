@@ -1,28 +1,261 @@
 package menu
 
 import (
+	"fmt"
 	"log"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
 
 	"github.com/knightpp/sni/generated/d_bus_menu"
+	"github.com/knightpp/sni/pkg/dispatch"
 
 	"github.com/godbus/dbus/v5"
 )
 
 func NewMenuServer(tree ItemTree) *MenuServer {
-	idToItem := make(map[int32]*Item)
-	tree.root.forEach(func(i *Item) {
-		idToItem[i.id] = i
-	})
-	return &MenuServer{
-		tree:     tree,
-		idToItem: idToItem,
+	m := &MenuServer{
+		tree:  tree,
+		theme: func() Theme { return ThemeLight },
+		emit:  func(d_bus_menu.Signal) error { return nil },
+		reportError: func(id int32, err error) {
+			log.Printf("menu item %d: %v", id, err)
+		},
+		dispatcher: new(dispatch.Serial),
 	}
+	m.index()
+	m.unsubscribe = tree.subscribe(m.Invalidate)
+	return m
 }
 
+// MenuServer implements com.canonical.dbusmenu for an ItemTree.
+//
+// Items may be changed from click handlers: changed properties are sent to
+// the host with ItemsPropertiesUpdated signal once the handler returns. The
+// whole menu can be replaced with SetTree. Properties bound to sources, see
+// Item.LabelFrom, are recomputed when sources change.
 type MenuServer struct {
 	*d_bus_menu.UnimplementedDbusmenu
+	// mu guards tree, idToItem, revision, nextID, unsubscribe, theme,
+	// reportError and dispatcher
+	mu       sync.Mutex
 	tree     ItemTree
 	idToItem map[int32]*Item
+	// revision is incremented on every layout change
+	revision uint32
+	// nextID is id for the next new item
+	nextID int32
+	// unsubscribe cancels subscriptions to sources of the tree
+	unsubscribe func()
+	// theme returns current theme of the host, see Item.ThemedIcon
+	theme func() Theme
+	// path is where the server is exported
+	path dbus.ObjectPath
+	// emit sends signals, it does nothing until the server is exported
+	emit func(d_bus_menu.Signal) error
+	// reportError is called with errors of click handlers
+	reportError func(id int32, err error)
+	// dispatcher runs click handlers
+	dispatcher dispatch.Dispatcher
+}
+
+// index rebuilds idToItem from tree, mu must be held.
+func (m *MenuServer) index() {
+	m.idToItem = make(map[int32]*Item)
+	m.tree.root.forEach(func(i *Item) {
+		id := i.ID()
+		m.idToItem[id] = i
+		if id >= m.nextID {
+			m.nextID = id + 1
+		}
+	})
+}
+
+// Export exports the server on conn at path. The connection is used to emit
+// signals.
+func (m *MenuServer) Export(conn *dbus.Conn, path dbus.ObjectPath) error {
+	if err := d_bus_menu.ExportDbusmenu(conn, path, m); err != nil {
+		return err
+	}
+	m.path = path
+	m.emit = func(s d_bus_menu.Signal) error {
+		return d_bus_menu.Emit(conn, s)
+	}
+	return nil
+}
+
+// SetTree replaces the menu with tree. Items of the new tree are matched to
+// the old ones by Item.Key, items without keys are matched by position.
+// Matched items keep their ids, so the host gets ItemsPropertiesUpdated
+// for changed properties and LayoutUpdated only for submenus whose items
+// were added, removed or reordered. Open submenus stay open.
+//
+// It's fine to rebuild the whole menu from application state on every
+// change and call SetTree, even from click handlers.
+func (m *MenuServer) SetTree(tree ItemTree) {
+	m.mu.Lock()
+	m.unsubscribe()
+	m.unsubscribe = tree.subscribe(m.Invalidate)
+	before := m.snapshotLocked()
+	d := treeDiff{nextID: m.nextID, relayout: make(map[*Item]bool)}
+	d.match(m.tree.root, tree.root)
+	m.tree = tree
+	m.nextID = d.nextID
+	m.index()
+	after := m.snapshotLocked()
+
+	// Layout of the topmost changed submenus is fetched again by the host,
+	// so their items don't need ItemsPropertiesUpdated.
+	var parents []int32
+	tree.root.forEachUntil(func(i *Item) bool {
+		if !d.relayout[i] {
+			return true
+		}
+		parents = append(parents, i.ID())
+		i.forEach(func(i *Item) { delete(after, i.ID()) })
+		return false
+	})
+	if len(parents) > 0 {
+		m.revision++
+	}
+	revision := m.revision
+	m.mu.Unlock()
+
+	m.emitProperties(diffProperties(before, after))
+	for _, parent := range parents {
+		err := m.emit(&d_bus_menu.Dbusmenu_LayoutUpdatedSignal{
+			Path: m.path,
+			Body: &d_bus_menu.Dbusmenu_LayoutUpdatedSignalBody{
+				Revision: revision,
+				Parent:   parent,
+			},
+		})
+		if err != nil {
+			log.Print("couldn't emit LayoutUpdated: ", err)
+		}
+	}
+}
+
+// RequestActivation asks the host to show item to the user, e.g. to open a
+// submenu from a global hotkey. timestamp is the time of the user action
+// that caused the request, it's used by the host for focus stealing
+// prevention. It returns an error if item isn't in the menu.
+func (m *MenuServer) RequestActivation(item *Item, timestamp uint32) error {
+	id := item.ID()
+	if found, ok := m.item(id); !ok || found != item {
+		return fmt.Errorf("menu item %d isn't in the served menu", id)
+	}
+	return m.emit(&d_bus_menu.Dbusmenu_ItemActivationRequestedSignal{
+		Path: m.path,
+		Body: &d_bus_menu.Dbusmenu_ItemActivationRequestedSignalBody{
+			Id:        id,
+			Timestamp: timestamp,
+		},
+	})
+}
+
+// treeDiff matches items of a new tree to an old one.
+type treeDiff struct {
+	nextID int32
+	// relayout has items of the new tree with changed children
+	relayout map[*Item]bool
+}
+
+// match gives to new the id of old and does the same for their children.
+func (d *treeDiff) match(old, new *Item) {
+	new.setID(old.ID())
+	oldChildren, newChildren := old.Children(), new.Children()
+	byKey := make(map[string]*Item)
+	var unkeyed []*Item
+	for _, child := range oldChildren {
+		if key := child.getKey(); key != "" {
+			byKey[key] = child
+		} else {
+			unkeyed = append(unkeyed, child)
+		}
+	}
+
+	changed := len(oldChildren) != len(newChildren)
+	for n, child := range newChildren {
+		var prev *Item
+		if key := child.getKey(); key != "" {
+			prev = byKey[key]
+			delete(byKey, key)
+		} else if len(unkeyed) > 0 {
+			prev, unkeyed = unkeyed[0], unkeyed[1:]
+		}
+		if prev == nil {
+			d.assign(child)
+			changed = true
+			continue
+		}
+		d.match(prev, child)
+		if n >= len(oldChildren) || oldChildren[n].ID() != child.ID() {
+			changed = true
+		}
+	}
+	if changed {
+		d.relayout[new] = true
+	}
+}
+
+// assign gives fresh ids to item and its descendants.
+func (d *treeDiff) assign(item *Item) {
+	item.forEach(func(i *Item) {
+		i.setID(d.nextID)
+		d.nextID++
+	})
+}
+
+// SetDispatcher sets where click handlers run, by default they run one at a
+// time on D-Bus goroutines, see dispatch.Serial. With an asynchronous
+// dispatcher the host gets a reply before the handler runs, so handler
+// errors are only passed to the error reporter.
+func (m *MenuServer) SetDispatcher(d dispatch.Dispatcher) *MenuServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatcher = d
+	return m
+}
+
+// SetErrorReporter sets a function that is called when a click handler
+// fails or panics, in the latter case err is *PanicError. By default errors
+// are logged.
+func (m *MenuServer) SetErrorReporter(fn func(id int32, err error)) *MenuServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reportError = fn
+	return m
+}
+
+// PanicError is a panic recovered from a click handler.
+type PanicError struct {
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("click handler panicked: %v", e.Value)
+}
+
+// SetThemeFunc sets a function that reports the current theme. It's called
+// on every GetLayout to choose between variants of themed icons. Default
+// theme is ThemeLight.
+func (m *MenuServer) SetThemeFunc(fn func() Theme) *MenuServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.theme = fn
+	return m
+}
+
+// currentTheme calls the function set by SetThemeFunc, mu must not be held.
+func (m *MenuServer) currentTheme() Theme {
+	m.mu.Lock()
+	theme := m.theme
+	m.mu.Unlock()
+	return theme()
 }
 
 type Layout = struct {
@@ -39,42 +272,233 @@ func (m *MenuServer) GetLayout(
 	recursionDepth int32,
 	propertyNames []string,
 ) (revision uint32, layout Layout, err *dbus.Error) {
-	layout = m.tree.ToLayout()
+	m.mu.Lock()
+	item, ok := m.idToItem[parentId]
+	revision = m.revision
+	m.mu.Unlock()
+	if !ok {
+		return 0, Layout{}, unknownID(parentId)
+	}
+	layout = item.toLayout(m.currentTheme(), recursionDepth, propertyNames)
 	log.Printf("GetLayout(parentId = %d, recursionDepth = %d,"+
 		"propertyNames = %+v) return %+v",
 		parentId, recursionDepth, propertyNames, layout)
 	return
 }
 
+func unknownID(id int32) *dbus.Error {
+	return dbus.MakeFailedError(fmt.Errorf("unknown menu item id %d", id))
+}
+
+// item returns item by id.
+func (m *MenuServer) item(id int32) (*Item, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.idToItem[id]
+	return item, ok
+}
+
 // GetGroupProperties is com.canonical.dbusmenu.GetGroupProperties method.
+// Empty ids means all items, unknown ids are skipped.
 func (m *MenuServer) GetGroupProperties(ids []int32, propertyNames []string) (properties []struct {
 	V0 int32
 	V1 map[string]dbus.Variant
 }, err *dbus.Error,
 ) {
 	log.Printf("GetGroupProperties(ids = %+v, propertyNames = %+v)", ids, propertyNames)
+	if len(ids) == 0 {
+		m.mu.Lock()
+		for id := range m.idToItem {
+			ids = append(ids, id)
+		}
+		m.mu.Unlock()
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	theme := m.currentTheme()
+	for _, id := range ids {
+		item, ok := m.item(id)
+		if !ok {
+			continue
+		}
+		properties = append(properties, struct {
+			V0 int32
+			V1 map[string]dbus.Variant
+		}{id, filterProperties(item.snapshot(theme), propertyNames)})
+	}
 	return
 }
 
 // GetProperty is com.canonical.dbusmenu.GetProperty method.
 func (m *MenuServer) GetProperty(id int32, name string) (value dbus.Variant, err *dbus.Error) {
 	log.Printf("GetProperty(id = %d, name = %s)", id, name)
+	item, ok := m.item(id)
+	if !ok {
+		return dbus.Variant{}, unknownID(id)
+	}
+	value, ok = item.snapshot(m.currentTheme())[name]
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(
+			fmt.Errorf("menu item %d has no property %q", id, name))
+	}
 	return
 }
 
-// Event is com.canonical.dbusmenu.Event method.
+// Event is com.canonical.dbusmenu.Event method. Errors of click handlers
+// are returned to the host.
 func (m *MenuServer) Event(id int32, eventId string, data dbus.Variant, timestamp uint32) (err *dbus.Error) {
 	log.Printf("Event(id = %d, eventId = %s, data = %s, timestamp = %d)",
 		id, eventId, data, timestamp)
-	if eventId == "clicked" {
-		item, ok := m.idToItem[id]
-		if ok && item.onClick != nil {
-			item.onClick()
-		}
+	item, ok := m.item(id)
+	if !ok {
+		return unknownID(id)
+	}
+	if handlerErr := m.event(item, eventId); handlerErr != nil {
+		return dbus.MakeFailedError(handlerErr)
 	}
 	return
 }
 
+// event dispatches handler of eventId and reports its errors. The error is
+// returned if the handler has finished by the time Dispatch returns.
+func (m *MenuServer) event(item *Item, eventId string) error {
+	if eventId != "clicked" {
+		return nil
+	}
+	onClick := item.clickHandler()
+	if onClick == nil {
+		return nil
+	}
+	m.mu.Lock()
+	dispatcher, reportError := m.dispatcher, m.reportError
+	m.mu.Unlock()
+
+	done := make(chan error, 1)
+	dispatcher.Dispatch(func() {
+		var err error
+		m.update(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+			err = onClick()
+		})
+		if err != nil {
+			reportError(item.ID(), err)
+		}
+		done <- err
+	})
+	select {
+	case err := <-done:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Invalidate recomputes properties bound to sources and sends changed ones
+// to the host. Sources like Value notify the server themselves, call
+// Invalidate when state that Func sources depend on changes.
+func (m *MenuServer) Invalidate() {
+	m.update(nil)
+}
+
+// update calls fn, recomputes bound properties and sends properties changed
+// since before fn was called. If fn replaces the tree, SetTree has sent the
+// changes made until then.
+func (m *MenuServer) update(fn func()) {
+	m.mu.Lock()
+	root := m.tree.root
+	before := m.snapshotLocked()
+	m.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+	m.mu.Lock()
+	if m.tree.root != root {
+		root = m.tree.root
+		before = m.snapshotLocked()
+	}
+	m.mu.Unlock()
+	root.forEach((*Item).refresh)
+	m.emitProperties(diffProperties(before, m.snapshot()))
+}
+
+// snapshot returns properties of every item by id.
+func (m *MenuServer) snapshot() map[int32]map[string]dbus.Variant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshotLocked()
+}
+
+func (m *MenuServer) snapshotLocked() map[int32]map[string]dbus.Variant {
+	theme := m.theme()
+	props := make(map[int32]map[string]dbus.Variant, len(m.idToItem))
+	for id, item := range m.idToItem {
+		props[id] = item.snapshot(theme)
+	}
+	return props
+}
+
+// emitProperties sends ItemsPropertiesUpdated unless body is empty.
+func (m *MenuServer) emitProperties(body *d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignalBody) {
+	if len(body.UpdatedProps) == 0 && len(body.RemovedProps) == 0 {
+		return
+	}
+	err := m.emit(&d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignal{
+		Path: m.path,
+		Body: body,
+	})
+	if err != nil {
+		log.Print("couldn't emit ItemsPropertiesUpdated: ", err)
+	}
+}
+
+// diffProperties compares properties of items present in both snapshots.
+func diffProperties(
+	before, after map[int32]map[string]dbus.Variant,
+) *d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignalBody {
+	body := &d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignalBody{}
+	ids := make([]int32, 0, len(after))
+	for id := range after {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		old, ok := before[id]
+		if !ok {
+			continue
+		}
+		updated := make(map[string]dbus.Variant)
+		for name, v := range after[id] {
+			if prev, ok := old[name]; !ok || !reflect.DeepEqual(prev, v) {
+				updated[name] = v
+			}
+		}
+		var removed []string
+		for name := range old {
+			if _, ok := after[id][name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+		sort.Strings(removed)
+		if len(updated) > 0 {
+			body.UpdatedProps = append(body.UpdatedProps, struct {
+				V0 int32
+				V1 map[string]dbus.Variant
+			}{id, updated})
+		}
+		if len(removed) > 0 {
+			body.RemovedProps = append(body.RemovedProps, struct {
+				V0 int32
+				V1 []string
+			}{id, removed})
+		}
+	}
+	return body
+}
+
 // EventGroup is com.canonical.dbusmenu.EventGroup method.
 func (m *MenuServer) EventGroup(events []struct {
 	V0 int32
@@ -84,6 +508,23 @@ func (m *MenuServer) EventGroup(events []struct {
 },
 ) (idErrors []int32, err *dbus.Error) {
 	log.Printf("EventGroup(events = %+v)", events)
+	failed := 0
+	for _, event := range events {
+		item, ok := m.item(event.V0)
+		if !ok {
+			idErrors = append(idErrors, event.V0)
+			continue
+		}
+		// Handler errors are reported, but the group can't carry them.
+		if m.event(item, event.V1) != nil {
+			failed++
+		}
+	}
+	// The spec requires an error when none of the events were handled.
+	if len(events) > 0 && len(idErrors)+failed == len(events) {
+		return idErrors, dbus.MakeFailedError(fmt.Errorf(
+			"no events handled: unknown ids %v, %d handlers failed", idErrors, failed))
+	}
 	return
 }
 
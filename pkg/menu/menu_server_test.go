@@ -0,0 +1,184 @@
+package menu_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/knightpp/sni/generated/d_bus_menu"
+	"github.com/knightpp/sni/pkg/dispatch"
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildMenu(files []string, dark bool) menu.ItemTree {
+	var recent []*menu.Item
+	for _, file := range files {
+		recent = append(recent, menu.NewItem().Key(file).LiteralLabel(file))
+	}
+	return menu.NewItem().Submenu(
+		menu.NewItem().Key("recent").Label("Recent").Submenu(recent...),
+		menu.NewItem().Key("dark").Label("Dark").
+			ToggleType(menu.ToggleTypeCheckmark).ToggleState(dark),
+	).Build()
+}
+
+func TestSetTree(t *testing.T) {
+	assert := assert.New(t)
+	server := menu.NewMenuServer(buildMenu([]string{"a.txt", "b.txt"}, false))
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	// Properties change only.
+	server.SetTree(buildMenu([]string{"a.txt", "b.txt"}, true))
+	require.Len(t, signals, 1)
+	props := signals[0].(*d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignal).Body
+	require.Len(t, props.UpdatedProps, 1)
+	assert.Equal(int32(4), props.UpdatedProps[0].V0)
+	assert.Equal(map[string]dbus.Variant{"toggle-state": dbus.MakeVariant(uint32(1))},
+		props.UpdatedProps[0].V1)
+
+	// New file in front: items keep ids, only "Recent" is laid out again.
+	signals = nil
+	tree := buildMenu([]string{"c.txt", "a.txt", "b.txt"}, true)
+	server.SetTree(tree)
+	require.Len(t, signals, 1)
+	layout := signals[0].(*d_bus_menu.Dbusmenu_LayoutUpdatedSignal).Body
+	assert.Equal(uint32(1), layout.Revision)
+	assert.Equal(int32(1), layout.Parent)
+	recent := tree.Root().Children()[0].Children()
+	assert.Equal([]int32{5, 2, 3}, []int32{recent[0].ID(), recent[1].ID(), recent[2].ID()})
+
+	revision, got, err := server.GetLayout(1, 0, []string{"label"})
+	assert.Nil(err)
+	assert.Equal(uint32(1), revision)
+	assert.Equal(menu.Layout{V0: 1, V1: map[string]dbus.Variant{
+		"label": dbus.MakeVariant("Recent"),
+	}}, got)
+
+	value, err := server.GetProperty(5, "label")
+	assert.Nil(err)
+	assert.Equal(dbus.MakeVariant("c.txt"), value)
+	_, err = server.GetProperty(42, "label")
+	assert.NotNil(err)
+}
+
+func TestSetTreeFromHandler(t *testing.T) {
+	tree := buildMenu([]string{"a.txt"}, false)
+	server := menu.NewMenuServer(tree)
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	// The new tree flips "Dark", it's sent once by SetTree.
+	dark := tree.Root().Children()[1]
+	dark.OnClick(func() {
+		server.SetTree(buildMenu([]string{"a.txt"}, true))
+	})
+	assert.Nil(t, server.Event(dark.ID(), "clicked", dbus.MakeVariant(""), 0))
+	require.Len(t, signals, 1)
+	props := signals[0].(*d_bus_menu.Dbusmenu_ItemsPropertiesUpdatedSignal).Body
+	require.Len(t, props.UpdatedProps, 1)
+	assert.Equal(t, map[string]dbus.Variant{"toggle-state": dbus.MakeVariant(uint32(1))},
+		props.UpdatedProps[0].V1)
+}
+
+func TestRequestActivation(t *testing.T) {
+	assert := assert.New(t)
+	recent := menu.NewItem().Label("Recent").Submenu(menu.NewItem().Label("a.txt"))
+	server := menu.NewMenuServer(menu.NewItem().Submenu(recent).Build())
+	var signals []d_bus_menu.Signal
+	menu.CaptureSignals(server, &signals)
+
+	assert.NoError(server.RequestActivation(recent, 1234))
+	require.Len(t, signals, 1)
+	assert.Equal(&d_bus_menu.Dbusmenu_ItemActivationRequestedSignalBody{
+		Id:        recent.ID(),
+		Timestamp: 1234,
+	}, signals[0].(*d_bus_menu.Dbusmenu_ItemActivationRequestedSignal).Body)
+
+	assert.Error(server.RequestActivation(menu.NewItem(), 0))
+}
+
+func TestHandlerErrors(t *testing.T) {
+	assert := assert.New(t)
+	failing := menu.NewItem().Label("Save").OnClickErr(func() error {
+		return errors.New("disk is full")
+	})
+	buggy := menu.NewItem().Label("Crash").OnClick(func() {
+		var m map[string]int
+		m["boom"]++
+	})
+	fine := menu.NewItem().Label("Fine").OnClick(func() {})
+	server := menu.NewMenuServer(menu.NewItem().Submenu(failing, buggy, fine).Build())
+	reported := make(map[int32]error)
+	server.SetErrorReporter(func(id int32, err error) { reported[id] = err })
+
+	err := server.Event(failing.ID(), "clicked", dbus.MakeVariant(""), 0)
+	require.NotNil(t, err)
+	assert.Equal("disk is full", err.Error())
+
+	err = server.Event(buggy.ID(), "clicked", dbus.MakeVariant(""), 0)
+	require.NotNil(t, err)
+	var panicErr *menu.PanicError
+	require.True(t, errors.As(reported[buggy.ID()], &panicErr))
+	assert.Contains(string(panicErr.Stack), "TestHandlerErrors")
+	assert.EqualError(reported[failing.ID()], "disk is full")
+
+	idErrors, err := server.EventGroup([]struct {
+		V0 int32
+		V1 string
+		V2 dbus.Variant
+		V3 uint32
+	}{
+		{fine.ID(), "clicked", dbus.MakeVariant(""), 0},
+		{42, "clicked", dbus.MakeVariant(""), 0},
+	})
+	assert.Nil(err)
+	assert.Equal([]int32{42}, idErrors)
+}
+
+func TestDispatcher(t *testing.T) {
+	assert := assert.New(t)
+	worker := dispatch.NewWorker()
+	release := make(chan struct{})
+	var clicks []string
+	slow := menu.NewItem().Label("Slow").OnClickErr(func() error {
+		<-release
+		clicks = append(clicks, "slow")
+		return errors.New("failed later")
+	})
+	fast := menu.NewItem().Label("Fast").OnClick(func() { clicks = append(clicks, "fast") })
+	server := menu.NewMenuServer(menu.NewItem().Submenu(slow, fast).Build()).
+		SetDispatcher(worker)
+	reported := make(chan error, 1)
+	server.SetErrorReporter(func(id int32, err error) { reported <- err })
+
+	// The reply doesn't wait for the handler, so there is no error.
+	assert.Nil(server.Event(slow.ID(), "clicked", dbus.MakeVariant(""), 0))
+	assert.Nil(server.Event(fast.ID(), "clicked", dbus.MakeVariant(""), 0))
+	close(release)
+	assert.EqualError(<-reported, "failed later")
+	require.NoError(t, worker.Close())
+	assert.Equal([]string{"slow", "fast"}, clicks)
+}
+
+func TestSettersWhileServing(t *testing.T) {
+	failing := menu.NewItem().Label("Save").OnClickErr(func() error { return errors.New("failed") })
+	server := menu.NewMenuServer(menu.NewItem().Submenu(failing).Build())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 0; n < 100; n++ {
+			server.GetLayout(0, -1, nil)
+			server.GetProperty(failing.ID(), "label")
+			server.Event(failing.ID(), "clicked", dbus.MakeVariant(""), 0)
+		}
+	}()
+	for n := 0; n < 100; n++ {
+		server.SetThemeFunc(func() menu.Theme { return menu.ThemeDark })
+		server.SetErrorReporter(func(int32, error) {})
+	}
+	<-done
+}
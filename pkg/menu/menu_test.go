@@ -39,3 +39,17 @@ func TestSimpleItem(t *testing.T) {
 		V2: nil,
 	}))
 }
+
+func TestNestedIds(t *testing.T) {
+	assert := assert.New(t)
+	inner := menu.NewItem().Label("Inner")
+	second := menu.NewItem().Label("Second")
+	tree := menu.NewItem().Submenu(
+		menu.NewItem().Label("First").Submenu(inner),
+		second,
+	).Build()
+
+	assert.Equal(int32(2), inner.ID())
+	assert.Equal(int32(3), second.ID())
+	assert.Equal(int32(0), tree.Root().ID())
+}
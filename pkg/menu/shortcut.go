@@ -0,0 +1,238 @@
+package menu
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Modifiers allowed by the dbusmenu spec.
+const (
+	ModifierControl = "Control"
+	ModifierAlt     = "Alt"
+	ModifierShift   = "Shift"
+	ModifierSuper   = "Super"
+)
+
+// modifierAliases maps lower-cased names people write to spec modifiers.
+var modifierAliases = map[string]string{
+	"control": ModifierControl,
+	"ctrl":    ModifierControl,
+	"ctl":     ModifierControl,
+	"alt":     ModifierAlt,
+	"option":  ModifierAlt,
+	"opt":     ModifierAlt,
+	"shift":   ModifierShift,
+	"super":   ModifierSuper,
+	"meta":    ModifierSuper,
+	"win":     ModifierSuper,
+	"cmd":     ModifierSuper,
+	"command": ModifierSuper,
+	"logo":    ModifierSuper,
+}
+
+// modifierDisplay is how modifiers are rendered by Shortcut.String.
+var modifierDisplay = map[string]string{
+	ModifierControl: "Ctrl",
+	ModifierAlt:     "Alt",
+	ModifierShift:   "Shift",
+	ModifierSuper:   "Super",
+}
+
+// keyAliases maps lower-cased key abbreviations to key names.
+var keyAliases = map[string]string{
+	"esc":    "Escape",
+	"del":    "Delete",
+	"ins":    "Insert",
+	"enter":  "Return",
+	"pgup":   "Page_Up",
+	"pgdn":   "Page_Down",
+	"pgdown": "Page_Down",
+	"space":  "space",
+	"plus":   "plus",
+	"minus":  "minus",
+}
+
+// Shortcut is a keyboard shortcut in the dbusmenu format. Each element is
+// one key press of a sequence: zero or more modifiers followed by a key.
+//
+// Shortcut can be passed to Item.Shortcut as is.
+type Shortcut [][]string
+
+// ParseShortcut parses human-readable shortcut such as "Ctrl+Shift+Q". Key
+// presses of a sequence are separated by spaces, e.g. "Ctrl+K Ctrl+S".
+// Modifier names are case-insensitive and common aliases like "Cmd" or
+// "Win" are accepted.
+func ParseShortcut(s string) (Shortcut, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("shortcut %q: empty", s)
+	}
+	shortcut := make(Shortcut, 0, len(fields))
+	for _, field := range fields {
+		press, err := parseKeyPress(field)
+		if err != nil {
+			return nil, fmt.Errorf("shortcut %q: %w", s, err)
+		}
+		shortcut = append(shortcut, press)
+	}
+	return shortcut, nil
+}
+
+// MustParseShortcut is like ParseShortcut but panics on error. It's meant
+// for constant shortcuts.
+func MustParseShortcut(s string) Shortcut {
+	shortcut, err := ParseShortcut(s)
+	if err != nil {
+		panic(err)
+	}
+	return shortcut
+}
+
+func parseKeyPress(s string) ([]string, error) {
+	var parts []string
+	switch {
+	case s == "+":
+		parts = []string{"+"}
+	case strings.HasSuffix(s, "++"):
+		parts = append(strings.Split(s[:len(s)-2], "+"), "+")
+	default:
+		parts = strings.Split(s, "+")
+	}
+
+	press := make([]string, 0, len(parts))
+	seen := make(map[string]bool)
+	for _, part := range parts[:len(parts)-1] {
+		mod, ok := modifierAliases[strings.ToLower(part)]
+		if !ok {
+			return nil, fmt.Errorf("unknown modifier %q", part)
+		}
+		if seen[mod] {
+			return nil, fmt.Errorf("duplicate modifier %q", part)
+		}
+		seen[mod] = true
+		press = append(press, mod)
+	}
+	key, err := normalizeKey(parts[len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+	return append(press, key), nil
+}
+
+func normalizeKey(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("missing key")
+	}
+	if _, ok := modifierAliases[strings.ToLower(key)]; ok {
+		return "", fmt.Errorf("missing key after modifier %q", key)
+	}
+	if alias, ok := keyAliases[strings.ToLower(key)]; ok {
+		return alias, nil
+	}
+	if utf8.RuneCountInString(key) == 1 {
+		return strings.ToUpper(key), nil
+	}
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return key, nil
+}
+
+// Validate checks that every key press consists of spec modifiers followed
+// by exactly one key. Use it for shortcuts that weren't made by
+// ParseShortcut.
+func (s Shortcut) Validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("shortcut: empty")
+	}
+	for i, press := range s {
+		if len(press) == 0 {
+			return fmt.Errorf("shortcut: key press %d is empty", i)
+		}
+		seen := make(map[string]bool)
+		for _, mod := range press[:len(press)-1] {
+			if _, ok := modifierDisplay[mod]; !ok {
+				return fmt.Errorf("shortcut: key press %d: %q is not one of "+
+					"Control, Alt, Shift or Super", i, mod)
+			}
+			if seen[mod] {
+				return fmt.Errorf("shortcut: key press %d: duplicate modifier %q", i, mod)
+			}
+			seen[mod] = true
+		}
+		key := press[len(press)-1]
+		if _, err := normalizeKey(key); err != nil {
+			return fmt.Errorf("shortcut: key press %d: %w", i, err)
+		}
+		if _, ok := modifierDisplay[key]; ok {
+			return fmt.Errorf("shortcut: key press %d: missing key after modifier %q", i, key)
+		}
+	}
+	return nil
+}
+
+// String returns human-readable form, e.g. "Ctrl+K Ctrl+S". The result can
+// be parsed back with ParseShortcut.
+func (s Shortcut) String() string {
+	presses := make([]string, 0, len(s))
+	for _, press := range s {
+		parts := make([]string, 0, len(press))
+		for i, part := range press {
+			if name, ok := modifierDisplay[part]; ok && i < len(press)-1 {
+				part = name
+			}
+			parts = append(parts, part)
+		}
+		presses = append(presses, strings.Join(parts, "+"))
+	}
+	return strings.Join(presses, " ")
+}
+
+// Mnemonic returns access key of label. In dbusmenu labels an underscore
+// marks the next character as the access key and a double underscore is a
+// literal underscore, e.g. "_File" has mnemonic 'F'.
+func Mnemonic(label string) (rune, bool) {
+	for i := 0; i < len(label); i++ {
+		if label[i] != '_' {
+			continue
+		}
+		if i+1 >= len(label) {
+			break
+		}
+		if label[i+1] == '_' {
+			i++
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(label[i+1:])
+		return r, true
+	}
+	return 0, false
+}
+
+// StripMnemonic removes mnemonic markers from label, e.g. "_Save__as" becomes
+// "Save_as".
+func StripMnemonic(label string) string {
+	var b strings.Builder
+	b.Grow(len(label))
+	for i := 0; i < len(label); i++ {
+		if label[i] == '_' {
+			if i+1 < len(label) && label[i+1] == '_' {
+				b.WriteByte('_')
+				i++
+			}
+			continue
+		}
+		b.WriteByte(label[i])
+	}
+	return b.String()
+}
+
+// EscapeMnemonic escapes underscores in label, so it's displayed literally
+// without an access key.
+func EscapeMnemonic(label string) string {
+	return strings.ReplaceAll(label, "_", "__")
+}
@@ -0,0 +1,69 @@
+package menu_test
+
+import (
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShortcut(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		in   string
+		want menu.Shortcut
+		str  string
+	}{
+		{"Ctrl+Shift+Q", menu.Shortcut{{"Control", "Shift", "Q"}}, "Ctrl+Shift+Q"},
+		{"ctrl+k ctrl+s", menu.Shortcut{{"Control", "K"}, {"Control", "S"}}, "Ctrl+K Ctrl+S"},
+		{"Cmd+Alt+Esc", menu.Shortcut{{"Super", "Alt", "Escape"}}, "Super+Alt+Escape"},
+		{"Ctrl++", menu.Shortcut{{"Control", "+"}}, "Ctrl++"},
+		{"F5", menu.Shortcut{{"F5"}}, "F5"},
+	}
+	for _, c := range cases {
+		got, err := menu.ParseShortcut(c.in)
+		if assert.NoError(err, c.in) {
+			assert.Equal(c.want, got, c.in)
+			assert.Equal(c.str, got.String(), c.in)
+			assert.NoError(got.Validate(), c.in)
+		}
+	}
+
+	for _, in := range []string{"", "Ctrl+", "Ctrl+Shift", "Hyper+Q", "Ctrl+Ctrl+Q"} {
+		_, err := menu.ParseShortcut(in)
+		assert.Error(err, in)
+	}
+}
+
+func TestShortcutValidate(t *testing.T) {
+	assert := assert.New(t)
+	assert.Error(menu.Shortcut{{"Ctrl", "Q"}}.Validate())
+	assert.Error(menu.Shortcut{{"Control"}}.Validate())
+	assert.Error(menu.Shortcut{{}}.Validate())
+	assert.NoError(menu.Shortcut{{"Control", "Alt", "Delete"}}.Validate())
+}
+
+func TestShortcutItem(t *testing.T) {
+	layout := menu.NewItem().
+		Shortcut(menu.MustParseShortcut("Ctrl+Q")).
+		Build().ToLayout()
+	assert.Equal(t, dbus.MakeVariant([][]string{{"Control", "Q"}}),
+		layout.V1["shortcut"])
+}
+
+func TestMnemonic(t *testing.T) {
+	assert := assert.New(t)
+	r, ok := menu.Mnemonic("_File")
+	assert.True(ok)
+	assert.Equal('F', r)
+	r, ok = menu.Mnemonic("Save__as _Copy")
+	assert.True(ok)
+	assert.Equal('C', r)
+	_, ok = menu.Mnemonic("snake__case")
+	assert.False(ok)
+
+	assert.Equal("Save_as Copy", menu.StripMnemonic("Save__as _Copy"))
+	assert.Equal("snake__case", menu.EscapeMnemonic("snake_case"))
+}
@@ -0,0 +1,228 @@
+package menu
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Enum is implemented by string types that have a fixed set of values.
+// FromStruct turns fields of such types into radio groups.
+type Enum interface {
+	EnumValues() []string
+}
+
+var (
+	enumType   = reflect.TypeOf((*Enum)(nil)).Elem()
+	lockerType = reflect.TypeOf((*sync.Locker)(nil)).Elem()
+)
+
+// FromStruct builds a settings menu from exported fields of the struct v
+// points to:
+//
+//   - bool fields become checkmark items;
+//   - string fields of a type implementing Enum, or with options in the tag,
+//     become submenus with radio items;
+//   - nested structs and non-nil pointers to structs become submenus;
+//   - fields implementing sync.Locker, such as embedded sync.Mutex, are
+//     skipped.
+//
+// Fields are configured with the "menu" tag, options are separated by
+// commas: `menu:"label=Dark mode"`, `menu:"options=light|dark"`,
+// `menu:"icon=weather-clear-night"`. Tag `menu:"-"` skips the field. Label
+// defaults to the field name split into words.
+//
+// When the user clicks an item the new value is written to the struct and
+// onChange is called with the dotted path of the field, e.g.
+// "Appearance.Theme". Clicks are handled on D-Bus goroutines, possibly at the
+// same time, so click handlers read and write fields with a lock held: v
+// itself if it implements sync.Locker, e.g. by embedding sync.Mutex, or a
+// private one otherwise. onChange is called after the lock is released. To
+// access the struct from other goroutines, embed a mutex and hold it.
+func FromStruct(v interface{}, onChange func(field string)) (ItemTree, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ItemTree{}, fmt.Errorf("menu: FromStruct expects a non-nil pointer to struct, got %T", v)
+	}
+	if onChange == nil {
+		onChange = func(string) {}
+	}
+	mu, ok := v.(sync.Locker)
+	if !ok {
+		mu = new(sync.Mutex)
+	}
+	b := structBuilder{mu: mu, onChange: onChange}
+	children, err := b.items(rv.Elem(), "")
+	if err != nil {
+		return ItemTree{}, err
+	}
+	return NewItem().Submenu(children...).Build(), nil
+}
+
+type fieldTag struct {
+	skip    bool
+	label   string
+	icon    string
+	options []string
+}
+
+func parseFieldTag(field reflect.StructField) (fieldTag, error) {
+	tag := fieldTag{label: EscapeMnemonic(humanize(field.Name))}
+	raw, ok := field.Tag.Lookup("menu")
+	if !ok {
+		return tag, nil
+	}
+	if raw == "-" {
+		tag.skip = true
+		return tag, nil
+	}
+	for _, opt := range strings.Split(raw, ",") {
+		if opt = strings.TrimSpace(opt); opt == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "label":
+			tag.label = value
+		case "icon":
+			tag.icon = value
+		case "options":
+			tag.options = strings.Split(value, "|")
+		default:
+			return tag, fmt.Errorf("menu: field %s: unknown tag option %q", field.Name, key)
+		}
+	}
+	return tag, nil
+}
+
+// structBuilder builds items for fields of a struct.
+type structBuilder struct {
+	// mu guards fields of the struct
+	mu       sync.Locker
+	onChange func(string)
+}
+
+func (b structBuilder) items(v reflect.Value, prefix string) ([]*Item, error) {
+	var items []*Item
+	t := v.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if !field.IsExported() || isLocker(field.Type) {
+			continue
+		}
+		tag, err := parseFieldTag(field)
+		if err != nil {
+			return nil, err
+		}
+		if tag.skip {
+			continue
+		}
+		path := prefix + field.Name
+		fv := v.Field(n)
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct {
+			fv = fv.Elem()
+		}
+
+		item := NewItem().Label(tag.label)
+		if tag.icon != "" {
+			item.IconName(tag.icon)
+		}
+		enum, isEnum := enumOf(fv)
+		switch {
+		case fv.Kind() == reflect.Bool:
+			b.boolItem(item, fv, path)
+		case fv.Kind() == reflect.String && (tag.options != nil || isEnum):
+			options := tag.options
+			if options == nil {
+				options = enum.EnumValues()
+			}
+			item.Submenu(b.radioItems(fv, options, path)...)
+		case fv.Kind() == reflect.Struct:
+			children, err := b.items(fv, path+".")
+			if err != nil {
+				return nil, err
+			}
+			item.Submenu(children...)
+		default:
+			return nil, fmt.Errorf("menu: field %s: unsupported type %s, "+
+				`use menu:"-" to skip it`, path, field.Type)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// enumOf returns fv as Enum, EnumValues may be declared on a pointer
+// receiver.
+func enumOf(fv reflect.Value) (Enum, bool) {
+	if fv.Type().Implements(enumType) {
+		return fv.Interface().(Enum), true
+	}
+	if fv.CanAddr() && reflect.PointerTo(fv.Type()).Implements(enumType) {
+		return fv.Addr().Interface().(Enum), true
+	}
+	return nil, false
+}
+
+func isLocker(t reflect.Type) bool {
+	return t.Implements(lockerType) || reflect.PointerTo(t).Implements(lockerType)
+}
+
+func (b structBuilder) boolItem(item *Item, fv reflect.Value, path string) {
+	item.ToggleType(ToggleTypeCheckmark).ToggleState(fv.Bool())
+	item.OnClick(func() {
+		b.mu.Lock()
+		fv.SetBool(!fv.Bool())
+		item.ToggleState(fv.Bool())
+		b.mu.Unlock()
+		b.onChange(path)
+	})
+}
+
+func (b structBuilder) radioItems(fv reflect.Value, options []string, path string) []*Item {
+	items := make([]*Item, len(options))
+	for n, option := range options {
+		option := option
+		items[n] = NewItem().
+			LiteralLabel(option).
+			ToggleType(ToggleTypeRadio).
+			ToggleState(fv.String() == option).
+			OnClick(func() {
+				b.mu.Lock()
+				if fv.String() == option {
+					b.mu.Unlock()
+					return
+				}
+				fv.SetString(option)
+				for m, other := range items {
+					other.ToggleState(options[m] == option)
+				}
+				b.mu.Unlock()
+				b.onChange(path)
+			})
+	}
+	return items
+}
+
+// humanize splits Go identifier into lower-case words starting with a
+// capital letter, e.g. "ShowHiddenFiles" becomes "Show hidden files".
+func humanize(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for n, r := range runes {
+		if n > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[n-1])
+			nextLower := n+1 < len(runes) && unicode.IsLower(runes[n+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[n-1])) {
+				b.WriteByte(' ')
+			}
+		}
+		if n > 0 && unicode.IsUpper(r) && (n+1 >= len(runes) || unicode.IsLower(runes[n+1])) {
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
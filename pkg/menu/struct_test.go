@@ -0,0 +1,109 @@
+package menu_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type theme string
+
+func (theme) EnumValues() []string { return []string{"light", "dark"} }
+
+type settings struct {
+	ShowHiddenFiles bool
+	Appearance      struct {
+		Theme theme
+		Size  string `menu:"label=Icon _size,options=small|large"`
+	}
+	Secret string `menu:"-"`
+}
+
+func TestFromStruct(t *testing.T) {
+	assert := assert.New(t)
+	s := settings{}
+	s.Appearance.Theme = "dark"
+	var changed []string
+	tree, err := menu.FromStruct(&s, func(field string) { changed = append(changed, field) })
+	require.NoError(t, err)
+
+	hidden := tree.Root().Children()[0]
+	appearance := tree.Root().Children()[1]
+	assert.Equal(dbus.MakeVariant("Show hidden files"), prop(t, hidden, "label"))
+	assert.Equal(dbus.MakeVariant(uint32(0)), prop(t, hidden, "toggle-state"))
+	assert.Equal(dbus.MakeVariant("Icon _size"), prop(t, appearance.Children()[1], "label"))
+
+	light, dark := appearance.Children()[0].Children()[0], appearance.Children()[0].Children()[1]
+	assert.Equal(dbus.MakeVariant(uint32(1)), prop(t, dark, "toggle-state"))
+
+	server := menu.NewMenuServer(tree)
+	assert.Nil(server.Event(hidden.ID(), "clicked", dbus.MakeVariant(""), 0))
+	assert.Nil(server.Event(light.ID(), "clicked", dbus.MakeVariant(""), 0))
+	assert.True(s.ShowHiddenFiles)
+	assert.Equal(theme("light"), s.Appearance.Theme)
+	assert.Equal(dbus.MakeVariant(uint32(1)), prop(t, hidden, "toggle-state"))
+	assert.Equal(dbus.MakeVariant(uint32(1)), prop(t, light, "toggle-state"))
+	assert.Equal(dbus.MakeVariant(uint32(0)), prop(t, dark, "toggle-state"))
+	assert.Equal([]string{"ShowHiddenFiles", "Appearance.Theme"}, changed)
+
+	_, err = menu.FromStruct(&struct{ Count int }{}, nil)
+	assert.EqualError(err, `menu: field Count: unsupported type int, use menu:"-" to skip it`)
+}
+
+func prop(t *testing.T, item *menu.Item, name string) dbus.Variant {
+	t.Helper()
+	v, ok := item.Property(name)
+	require.True(t, ok, name)
+	return v
+}
+
+type size string
+
+func (*size) EnumValues() []string { return []string{"small", "large"} }
+
+type lockedSettings struct {
+	sync.Mutex
+	Bold bool
+	Size size
+}
+
+func TestFromStructConcurrentClicks(t *testing.T) {
+	s := lockedSettings{Size: "small"}
+	changes := make(chan string, 100)
+	tree, err := menu.FromStruct(&s, func(field string) { changes <- field })
+	require.NoError(t, err)
+
+	// The embedded mutex is not a menu item, Size has pointer receiver
+	// EnumValues.
+	require.Len(t, tree.Root().Children(), 2)
+	sizes := tree.Root().Children()[1].Children()
+	require.Len(t, sizes, 2)
+	assert.Equal(t, dbus.MakeVariant("large"), prop(t, sizes[1], "label"))
+
+	server := menu.NewMenuServer(tree)
+	bold := tree.Root().Children()[0]
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			server.Event(bold.ID(), "clicked", dbus.MakeVariant(""), 0)
+			server.Event(sizes[n%2].ID(), "clicked", dbus.MakeVariant(""), 0)
+			s.Lock()
+			_ = s.Bold
+			s.Unlock()
+		}(n)
+	}
+	wg.Wait()
+
+	s.Lock()
+	defer s.Unlock()
+	// Ten toggles leave it unchecked.
+	assert.False(t, s.Bold)
+	assert.Equal(t, dbus.MakeVariant(uint32(0)), prop(t, bold, "toggle-state"))
+}
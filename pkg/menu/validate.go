@@ -0,0 +1,168 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidationError is a problem found by ItemTree.Validate.
+type ValidationError struct {
+	// Path is a path to the item made of labels without mnemonics, or
+	// "#index" for items without label, e.g. "File > #2".
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// separatorProps are properties that make no sense on separators.
+var separatorProps = []string{
+	"label", "icon-name", "icon-data", "toggle-type", "toggle-state",
+	"shortcut", "children-display",
+}
+
+// Validate checks the tree against the dbusmenu spec. All problems are
+// reported at once, each one is *ValidationError. Hosts don't report
+// invalid menus and render them in their own ways, so it's worth calling
+// Validate in tests.
+func (tree ItemTree) Validate() error {
+	if tree.root == nil {
+		return &ValidationError{Path: "root", Msg: "tree is empty, build it with Item.Build"}
+	}
+	v := validator{seen: make(map[*Item]bool)}
+	v.item(tree.root, "root")
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	seen map[*Item]bool
+	errs []error
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) item(i *Item, path string) {
+	if v.seen[i] {
+		v.errorf(path, "item is used more than once")
+		return
+	}
+	v.seen[i] = true
+
+	props := i.snapshot(ThemeLight)
+	children := i.Children()
+
+	isSeparator := false
+	if t, ok := props["type"]; ok {
+		switch t.Value() {
+		case "separator":
+			isSeparator = true
+		case "standard":
+		default:
+			v.errorf(path, "type must be %q or %q, got %v", "standard", "separator", t.Value())
+		}
+	}
+	if isSeparator {
+		for _, name := range separatorProps {
+			if _, ok := props[name]; ok {
+				v.errorf(path, "separator has %s", name)
+			}
+		}
+		if len(children) > 0 {
+			v.errorf(path, "separator has submenu")
+		}
+	}
+
+	if tt, ok := props["toggle-type"]; ok {
+		switch tt.Value() {
+		case ToggleTypeCheckmark, ToggleTypeRadio, ToggleType(""):
+		default:
+			v.errorf(path, "unknown toggle-type %v", tt.Value())
+		}
+	} else if _, ok := props["toggle-state"]; ok && !isSeparator {
+		v.errorf(path, "toggle-state without toggle-type")
+	}
+
+	if d, ok := props["disposition"]; ok {
+		switch d.Value() {
+		case DispositionNormal, DispositionInformative, DispositionWarning, DispositionAlert:
+		default:
+			v.errorf(path, "unknown disposition %v", d.Value())
+		}
+	}
+
+	if cd, ok := props["children-display"]; ok && cd.Value() != "submenu" && cd.Value() != "" {
+		v.errorf(path, "children-display must be %q, got %v", "submenu", cd.Value())
+	}
+
+	if s, ok := props["shortcut"]; ok && !isSeparator {
+		var shortcut [][]string
+		if err := s.Store(&shortcut); err != nil {
+			v.errorf(path, "shortcut: %v", err)
+		} else if err := Shortcut(shortcut).Validate(); err != nil {
+			v.errorf(path, "%v", err)
+		}
+	}
+
+	groupErrs := radioGroups(children)
+	for n, child := range children {
+		p := childPath(path, n, child)
+		if msg, ok := groupErrs[n]; ok {
+			v.errorf(p, "%s", msg)
+		}
+		v.item(child, p)
+	}
+}
+
+// radioGroups checks runs of adjacent radio items, hosts treat such runs as
+// groups. Problems are keyed by index of the first item of the group.
+func radioGroups(children []*Item) map[int]string {
+	errs := make(map[int]string)
+	start := 0
+	for n := 0; n <= len(children); n++ {
+		if n < len(children) && isRadio(children[n]) {
+			continue
+		}
+		group := children[start:n]
+		start = n + 1
+		if len(group) == 0 {
+			continue
+		}
+		first := n - len(group)
+		if len(group) == 1 {
+			errs[first] = "radio item is not in a group, put radio items next to each other"
+			continue
+		}
+		checked := 0
+		for _, item := range group {
+			if state, ok := item.Property("toggle-state"); ok && state.Value() == uint32(1) {
+				checked++
+			}
+		}
+		if checked > 1 {
+			errs[first] = fmt.Sprintf("%d radio items of the group are checked", checked)
+		}
+	}
+	return errs
+}
+
+func isRadio(i *Item) bool {
+	tt, ok := i.Property("toggle-type")
+	return ok && tt.Value() == ToggleTypeRadio
+}
+
+func childPath(parent string, n int, child *Item) string {
+	name := fmt.Sprintf("#%d", n)
+	if label, ok := child.Property("label"); ok {
+		if s, ok := label.Value().(string); ok && s != "" {
+			name = StripMnemonic(s)
+		}
+	}
+	if parent == "root" {
+		return name
+	}
+	return parent + " > " + name
+}
@@ -0,0 +1,45 @@
+package menu_test
+
+import (
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	reused := menu.NewItem().Label("Reused")
+	tree := menu.NewItem().Submenu(
+		menu.NewItem().Label("_File").Submenu(
+			menu.NewItem().Separator(true).Label("Oops"),
+			menu.NewItem().Label("Lonely").ToggleType(menu.ToggleTypeRadio),
+			menu.NewItem().Label("State").ToggleState(true),
+		),
+		menu.NewItem().Label("A").ToggleType(menu.ToggleTypeRadio).ToggleState(true),
+		menu.NewItem().Label("B").ToggleType(menu.ToggleTypeRadio).ToggleState(true),
+		menu.NewItem().Disposition("loud"),
+		menu.NewItem().Label("Keys").Shortcut([][]string{{"Hyper", "K"}}),
+		reused,
+		reused,
+	).Build()
+
+	assert.EqualError(tree.Validate(), `File > Oops: separator has label
+File > Lonely: radio item is not in a group, put radio items next to each other
+File > State: toggle-state without toggle-type
+A: 2 radio items of the group are checked
+#3: unknown disposition loud
+Keys: shortcut: key press 0: "Hyper" is not one of Control, Alt, Shift or Super
+Reused: item is used more than once`)
+
+	valid := menu.NewItem().Submenu(
+		menu.NewItem().Label("Small").ToggleType(menu.ToggleTypeRadio).ToggleState(true),
+		menu.NewItem().Label("Large").ToggleType(menu.ToggleTypeRadio),
+		menu.NewItem().Separator(true),
+		menu.NewItem().Label("Quit").Shortcut(menu.MustParseShortcut("Ctrl+Q")),
+	).Build()
+	assert.NoError(valid.Validate())
+
+	assert.EqualError(menu.ItemTree{}.Validate(), "root: tree is empty, build it with Item.Build")
+}
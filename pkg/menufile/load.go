@@ -0,0 +1,279 @@
+package menufile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds menu from data. All problems found in the document are
+// reported at once, each one is *Error. The menu is checked with
+// menu.ItemTree.Validate as well, such problems are *menu.ValidationError.
+//
+// Positions are known for JSON and YAML documents; for TOML only syntax
+// errors have positions, other errors have Path set.
+func Load(data []byte, format Format, actions Actions) (menu.ItemTree, error) {
+	node, err := parse(data, format)
+	if err != nil {
+		return menu.ItemTree{}, err
+	}
+	l := loader{actions: actions}
+	root := l.document(node)
+	if err := errors.Join(l.errs...); err != nil {
+		return menu.ItemTree{}, err
+	}
+	tree := root.Build()
+	if err := tree.Validate(); err != nil {
+		return menu.ItemTree{}, err
+	}
+	return tree, nil
+}
+
+// parse converts document to yaml.Node, so all formats share one loader.
+func parse(data []byte, format Format) (*yaml.Node, error) {
+	switch format {
+	case FormatJSON:
+		if err := checkJSON(data); err != nil {
+			return nil, err
+		}
+		return parseYAML(data)
+	case FormatYAML:
+		return parseYAML(data)
+	case FormatTOML:
+		return parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unknown menu file format %q", format)
+	}
+}
+
+// checkJSON reports JSON syntax errors with positions. YAML parser accepts
+// JSON, but its messages are confusing for JSON documents.
+func checkJSON(data []byte) error {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset points after the offending byte.
+		line, col := position(data, syntaxErr.Offset-1)
+		return &Error{Line: line, Column: col, Msg: syntaxErr.Error()}
+	}
+	return err
+}
+
+// position converts byte offset to 1-based line and column.
+func position(data []byte, offset int64) (line, col int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+var yamlLineRe = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func parseYAML(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return nil, &Error{Line: line, Msg: m[2]}
+		}
+		return nil, &Error{Msg: err.Error()}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, &Error{Msg: "empty document"}
+	}
+	return doc.Content[0], nil
+}
+
+func parseTOML(data []byte) (*yaml.Node, error) {
+	var v map[string]interface{}
+	if err := toml.Unmarshal(data, &v); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &Error{
+				Line:   parseErr.Position.Line,
+				Column: parseErr.Position.Col,
+				Msg:    parseErr.Message,
+			}
+		}
+		return nil, &Error{Msg: err.Error()}
+	}
+	return toNode(v), nil
+}
+
+// toNode converts decoded TOML value to yaml.Node without positions.
+func toNode(v interface{}) *yaml.Node {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range keys {
+			n.Content = append(n.Content, toNode(k), toNode(v[k]))
+		}
+		return n
+	case []map[string]interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			n.Content = append(n.Content, toNode(item))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			n.Content = append(n.Content, toNode(item))
+		}
+		return n
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float",
+			Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+	}
+}
+
+type loader struct {
+	actions Actions
+	errs    []error
+}
+
+func (l *loader) errorf(n *yaml.Node, path, format string, args ...interface{}) {
+	l.errs = append(l.errs, &Error{
+		Line:   n.Line,
+		Column: n.Column,
+		Path:   path,
+		Msg:    fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *loader) document(n *yaml.Node) *menu.Item {
+	root := menu.NewItem()
+	if n.Kind != yaml.MappingNode {
+		l.errorf(n, "", "document must be a mapping with items")
+		return root
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch key.Value {
+		case "items":
+			root.Submenu(l.items(value, "items")...)
+		default:
+			l.errorf(key, key.Value, "unknown key")
+		}
+	}
+	return root
+}
+
+func (l *loader) items(n *yaml.Node, path string) []*menu.Item {
+	if n.Kind != yaml.SequenceNode {
+		l.errorf(n, path, "expected a list of items")
+		return nil
+	}
+	items := make([]*menu.Item, 0, len(n.Content))
+	for i, child := range n.Content {
+		items = append(items, l.item(child, fmt.Sprintf("%s[%d]", path, i)))
+	}
+	return items
+}
+
+func (l *loader) item(n *yaml.Node, path string) *menu.Item {
+	item := menu.NewItem()
+	if n.Kind != yaml.MappingNode {
+		l.errorf(n, path, "expected an item")
+		return item
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		p := path + "." + key.Value
+		switch key.Value {
+		case "label":
+			item.Label(l.str(value, p))
+		case "icon":
+			item.IconName(l.str(value, p))
+		case "separator":
+			item.Separator(l.bool(value, p))
+		case "toggle":
+			switch tt := menu.ToggleType(l.str(value, p)); tt {
+			case menu.ToggleTypeCheckmark, menu.ToggleTypeRadio:
+				item.ToggleType(tt)
+			default:
+				l.errorf(value, p, "toggle must be %q or %q, got %q",
+					menu.ToggleTypeCheckmark, menu.ToggleTypeRadio, tt)
+			}
+		case "checked":
+			item.ToggleState(l.bool(value, p))
+		case "enabled":
+			item.CanBeActivated(l.bool(value, p))
+		case "visible":
+			item.Visible(l.bool(value, p))
+		case "shortcut":
+			shortcut, err := menu.ParseShortcut(l.str(value, p))
+			if err != nil {
+				l.errorf(value, p, "%v", err)
+				continue
+			}
+			item.Shortcut(shortcut)
+		case "disposition":
+			switch d := menu.Disposition(l.str(value, p)); d {
+			case menu.DispositionNormal, menu.DispositionInformative,
+				menu.DispositionWarning, menu.DispositionAlert:
+				item.Disposition(d)
+			default:
+				l.errorf(value, p, "unknown disposition %q", d)
+			}
+		case "action":
+			name := l.str(value, p)
+			fn, ok := l.actions[name]
+			if !ok {
+				l.errorf(value, p, "unknown action %q", name)
+				continue
+			}
+			item.OnClick(fn)
+		case "submenu":
+			item.Submenu(l.items(value, p)...)
+		default:
+			l.errorf(key, p, "unknown key")
+		}
+	}
+	return item
+}
+
+func (l *loader) str(n *yaml.Node, path string) string {
+	if n.Kind != yaml.ScalarNode {
+		l.errorf(n, path, "expected a string")
+		return ""
+	}
+	return n.Value
+}
+
+func (l *loader) bool(n *yaml.Node, path string) bool {
+	var b bool
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" || n.Decode(&b) != nil {
+		l.errorf(n, path, "expected a boolean, got %q", n.Value)
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package menufile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/knightpp/sni/pkg/menu"
+
+	"github.com/BurntSushi/toml"
+	"github.com/godbus/dbus/v5"
+	"gopkg.in/yaml.v3"
+)
+
+type document struct {
+	Items []entry `json:"items" yaml:"items" toml:"items"`
+}
+
+type entry struct {
+	Label       string  `json:"label,omitempty" yaml:"label,omitempty" toml:"label,omitempty"`
+	Icon        string  `json:"icon,omitempty" yaml:"icon,omitempty" toml:"icon,omitempty"`
+	Separator   bool    `json:"separator,omitempty" yaml:"separator,omitempty" toml:"separator,omitempty"`
+	Toggle      string  `json:"toggle,omitempty" yaml:"toggle,omitempty" toml:"toggle,omitempty"`
+	Checked     bool    `json:"checked,omitempty" yaml:"checked,omitempty" toml:"checked,omitempty"`
+	Enabled     *bool   `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	Visible     *bool   `json:"visible,omitempty" yaml:"visible,omitempty" toml:"visible,omitempty"`
+	Shortcut    string  `json:"shortcut,omitempty" yaml:"shortcut,omitempty" toml:"shortcut,omitempty"`
+	Disposition string  `json:"disposition,omitempty" yaml:"disposition,omitempty" toml:"disposition,omitempty"`
+	Submenu     []entry `json:"submenu,omitempty" yaml:"submenu,omitempty" toml:"submenu,omitempty"`
+}
+
+// Marshal serializes tree to a document, it's meant for debugging. Actions
+// can't be recovered from handlers, so they are not written.
+func Marshal(tree menu.ItemTree, format Format) ([]byte, error) {
+	doc := document{Items: entries(tree.Root().Children())}
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(doc, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(doc)
+	case FormatTOML:
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(doc)
+		return buf.Bytes(), err
+	default:
+		return nil, fmt.Errorf("unknown menu file format %q", format)
+	}
+}
+
+func entries(items []*menu.Item) []entry {
+	list := make([]entry, 0, len(items))
+	for _, item := range items {
+		e := entry{
+			Label:       propString(item, "label"),
+			Icon:        propString(item, "icon-name"),
+			Separator:   propString(item, "type") == "separator",
+			Toggle:      propString(item, "toggle-type"),
+			Disposition: propString(item, "disposition"),
+			Submenu:     entries(item.Children()),
+		}
+		if v, ok := item.Property("toggle-state"); ok {
+			state, _ := v.Value().(uint32)
+			e.Checked = state == 1
+		}
+		if v, ok := item.Property("enabled"); ok {
+			b, _ := v.Value().(bool)
+			e.Enabled = &b
+		}
+		if v, ok := item.Property("visible"); ok {
+			b, _ := v.Value().(bool)
+			e.Visible = &b
+		}
+		if v, ok := item.Property("shortcut"); ok {
+			shortcut, _ := v.Value().([][]string)
+			e.Shortcut = menu.Shortcut(shortcut).String()
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// propString returns string property, including named string types such as
+// menu.ToggleType.
+func propString(item *menu.Item, name string) string {
+	v, ok := item.Property(name)
+	if !ok {
+		return ""
+	}
+	return variantString(v)
+}
+
+func variantString(v dbus.Variant) string {
+	rv := reflect.ValueOf(v.Value())
+	if rv.Kind() != reflect.String {
+		return ""
+	}
+	return rv.String()
+}
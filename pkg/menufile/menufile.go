@@ -0,0 +1,108 @@
+// Package menufile builds menus from JSON, YAML or TOML documents.
+//
+// A document has a list of items, every item may have a submenu:
+//
+//	items:
+//	  - label: _File
+//	    submenu:
+//	      - label: Open
+//	        icon: document-open
+//	        shortcut: Ctrl+O
+//	        action: open
+//	      - separator: true
+//	      - label: Quit
+//	        action: quit
+//	        disposition: warning
+//	  - label: Dark mode
+//	    toggle: checkmark
+//	    checked: true
+//	    action: toggle-dark
+//
+// Item keys are label, icon, separator, toggle ("checkmark" or "radio"),
+// checked, enabled, visible, shortcut (see menu.ParseShortcut), disposition,
+// action and submenu. Actions are looked up by name in Actions passed to
+// Load.
+package menufile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/knightpp/sni/pkg/menu"
+)
+
+// Format is a document format.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// FormatFromPath returns format by file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return "", fmt.Errorf("unknown menu file format %q", ext)
+	}
+}
+
+// Actions is a registry of handlers that menu items refer to by name.
+type Actions map[string]func()
+
+// Register adds fn under name and returns a for chaining.
+func (a Actions) Register(name string, fn func()) Actions {
+	a[name] = fn
+	return a
+}
+
+// Error describes a problem in a document. Line and Column are 1-based and
+// are zero when position is unknown, in this case Path tells where the
+// problem is.
+type Error struct {
+	Line   int
+	Column int
+	// Path is a path to the value, e.g. "items[0].submenu[1].label"
+	Path string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	var pos string
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		pos = fmt.Sprintf("%d:%d: ", e.Line, e.Column)
+	case e.Line > 0:
+		pos = fmt.Sprintf("%d: ", e.Line)
+	}
+	if e.Path != "" {
+		return pos + e.Path + ": " + e.Msg
+	}
+	return pos + e.Msg
+}
+
+// LoadFile reads menu from a file, format is chosen by file extension.
+func LoadFile(path string, actions Actions) (menu.ItemTree, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return menu.ItemTree{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return menu.ItemTree{}, err
+	}
+	m, err := Load(data, format, actions)
+	if err != nil {
+		return menu.ItemTree{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,158 @@
+package menufile_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/menufile"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlMenu = `
+items:
+  - label: _File
+    submenu:
+      - label: Open
+        icon: document-open
+        shortcut: Ctrl+O
+        action: open
+      - separator: true
+      - label: Quit
+        action: quit
+        disposition: warning
+  - label: Dark mode
+    toggle: checkmark
+    checked: true
+    enabled: false
+`
+
+const jsonMenu = `{
+  "items": [
+    {"label": "_File", "submenu": [
+      {"label": "Open", "icon": "document-open", "shortcut": "Ctrl+O", "action": "open"},
+      {"separator": true},
+      {"label": "Quit", "action": "quit", "disposition": "warning"}
+    ]},
+    {"label": "Dark mode", "toggle": "checkmark", "checked": true, "enabled": false}
+  ]
+}`
+
+const tomlMenu = `
+[[items]]
+label = "_File"
+
+  [[items.submenu]]
+  label = "Open"
+  icon = "document-open"
+  shortcut = "Ctrl+O"
+  action = "open"
+
+  [[items.submenu]]
+  separator = true
+
+  [[items.submenu]]
+  label = "Quit"
+  action = "quit"
+  disposition = "warning"
+
+[[items]]
+label = "Dark mode"
+toggle = "checkmark"
+checked = true
+enabled = false
+`
+
+func TestLoad(t *testing.T) {
+	docs := map[menufile.Format]string{
+		menufile.FormatYAML: yamlMenu,
+		menufile.FormatJSON: jsonMenu,
+		menufile.FormatTOML: tomlMenu,
+	}
+	for format, doc := range docs {
+		t.Run(string(format), func(t *testing.T) {
+			assert := assert.New(t)
+			var clicked []string
+			actions := menufile.Actions{}.
+				Register("open", func() { clicked = append(clicked, "open") }).
+				Register("quit", func() { clicked = append(clicked, "quit") })
+
+			tree, err := menufile.Load([]byte(doc), format, actions)
+			require.NoError(t, err)
+
+			file := tree.Root().Children()[0]
+			assert.Equal(dbus.MakeVariant("_File"), mustProp(t, file, "label"))
+			open, quit := file.Children()[0], file.Children()[2]
+			assert.Equal(dbus.MakeVariant([][]string{{"Control", "O"}}),
+				mustProp(t, open, "shortcut"))
+			assert.Equal(dbus.MakeVariant(menu.DispositionWarning),
+				mustProp(t, quit, "disposition"))
+			dark := tree.Root().Children()[1]
+			assert.Equal(dbus.MakeVariant(uint32(1)), mustProp(t, dark, "toggle-state"))
+			assert.Equal(dbus.MakeVariant(false), mustProp(t, dark, "enabled"))
+
+			server := menu.NewMenuServer(tree)
+			assert.Nil(server.Event(quit.ID(), "clicked", dbus.MakeVariant(""), 0))
+			assert.Nil(server.Event(open.ID(), "clicked", dbus.MakeVariant(""), 0))
+			assert.Equal([]string{"quit", "open"}, clicked)
+
+			// Round trip without actions keeps the layout.
+			data, err := menufile.Marshal(tree, format)
+			require.NoError(t, err)
+			again, err := menufile.Load(data, format, nil)
+			require.NoError(t, err, string(data))
+			assert.Equal(tree.ToLayout(), again.ToLayout())
+		})
+	}
+}
+
+func mustProp(t *testing.T, item *menu.Item, name string) dbus.Variant {
+	t.Helper()
+	v, ok := item.Property(name)
+	require.True(t, ok, name)
+	return v
+}
+
+func TestLoadErrors(t *testing.T) {
+	assert := assert.New(t)
+	doc := `
+items:
+  - label: Open
+    action: missing
+  - label: Quit
+    toggle: switch
+    colour: red
+`
+	_, err := menufile.Load([]byte(doc), menufile.FormatYAML, nil)
+	require.Error(t, err)
+	assert.Equal(`4:13: items[0].action: unknown action "missing"
+6:13: items[1].toggle: toggle must be "checkmark" or "radio", got "switch"
+7:5: items[1].colour: unknown key`, err.Error())
+
+	_, err = menufile.Load([]byte(`{"items": [}`), menufile.FormatJSON, nil)
+	var docErr *menufile.Error
+	require.True(t, errors.As(err, &docErr))
+	assert.Equal(1, docErr.Line)
+	assert.Equal(12, docErr.Column)
+
+	_, err = menufile.Load([]byte("[[items]]\nlabel = \"x\"\nchecked = \"yes\"\n"),
+		menufile.FormatTOML, nil)
+	require.Error(t, err)
+	assert.Equal(`items[0].checked: expected a boolean, got "yes"`, err.Error())
+}
+
+func TestLoadInvalidMenu(t *testing.T) {
+	doc := `
+items:
+  - label: Small
+    toggle: radio
+  - separator: true
+    label: Oops
+`
+	_, err := menufile.Load([]byte(doc), menufile.FormatYAML, nil)
+	assert.EqualError(t, err, `Small: radio item is not in a group, put radio items next to each other
+Oops: separator has label`)
+}
@@ -2,40 +2,135 @@ package sni
 
 import (
 	"log"
+	"runtime/debug"
+	"sync"
 
 	"github.com/knightpp/sni/generated/status_notifier_item"
+	"github.com/knightpp/sni/pkg/dispatch"
 
 	"github.com/godbus/dbus/v5"
 )
 
 type SniServer struct {
 	*status_notifier_item.StatusNotifierItem
+
+	// mu guards fields below
+	mu                  sync.Mutex
+	dispatcher          dispatch.Dispatcher
+	onActivate          func(x, y int32)
+	onSecondaryActivate func(x, y int32)
+	onContextMenu       func(x, y int32)
+	onScroll            func(delta int32, orientation string)
 }
 
 func NewSniServer() *SniServer {
-	return &SniServer{}
+	return &SniServer{dispatcher: new(dispatch.Serial)}
+}
+
+// SetDispatcher sets where callbacks run, by default they run one at a time
+// on D-Bus goroutines, see dispatch.Serial.
+func (s *SniServer) SetDispatcher(d dispatch.Dispatcher) *SniServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatcher = d
+	return s
+}
+
+// OnActivate sets callback for primary activation, e.g. a left click. x and
+// y are screen coordinates, they may be zero if unknown.
+func (s *SniServer) OnActivate(fn func(x, y int32)) *SniServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onActivate = fn
+	return s
+}
+
+// OnSecondaryActivate sets callback for secondary activation, e.g. a middle
+// click.
+func (s *SniServer) OnSecondaryActivate(fn func(x, y int32)) *SniServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSecondaryActivate = fn
+	return s
+}
+
+// OnContextMenu sets callback called when the host asks the item to show its
+// own context menu. Hosts don't call it for items with a dbusmenu.
+func (s *SniServer) OnContextMenu(fn func(x, y int32)) *SniServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onContextMenu = fn
+	return s
+}
+
+// OnScroll sets callback for mouse wheel, orientation is "horizontal" or
+// "vertical".
+func (s *SniServer) OnScroll(fn func(delta int32, orientation string)) *SniServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onScroll = fn
+	return s
+}
+
+// dispatch runs fn with the dispatcher, panics are logged.
+func (s *SniServer) dispatch(name string, fn func()) {
+	s.mu.Lock()
+	dispatcher := s.dispatcher
+	s.mu.Unlock()
+	dispatcher.Dispatch(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("%s callback panicked: %v\n%s", name, r, debug.Stack())
+			}
+		}()
+		fn()
+	})
 }
 
 // ContextMenu is org.kde.StatusNotifierItem.ContextMenu method.
 func (s *SniServer) ContextMenu(x, y int32) (err *dbus.Error) {
 	log.Printf("ContextMenu(x = %d, y = %d)", x, y)
+	s.mu.Lock()
+	fn := s.onContextMenu
+	s.mu.Unlock()
+	if fn != nil {
+		s.dispatch("ContextMenu", func() { fn(x, y) })
+	}
 	return nil
 }
 
 // Activate is org.kde.StatusNotifierItem.Activate method.
 func (s *SniServer) Activate(x, y int32) (err *dbus.Error) {
 	log.Printf("Activate(x = %d, y = %d)", x, y)
+	s.mu.Lock()
+	fn := s.onActivate
+	s.mu.Unlock()
+	if fn != nil {
+		s.dispatch("Activate", func() { fn(x, y) })
+	}
 	return nil
 }
 
 // SecondaryActivate is org.kde.StatusNotifierItem.SecondaryActivate method.
 func (s *SniServer) SecondaryActivate(x, y int32) (err *dbus.Error) {
 	log.Printf("SecondaryActivate(x = %d, y = %d)", x, y)
+	s.mu.Lock()
+	fn := s.onSecondaryActivate
+	s.mu.Unlock()
+	if fn != nil {
+		s.dispatch("SecondaryActivate", func() { fn(x, y) })
+	}
 	return nil
 }
 
 // Scroll is org.kde.StatusNotifierItem.Scroll method.
 func (s *SniServer) Scroll(delta int32, orientation string) (err *dbus.Error) {
 	log.Printf("Scroll(delta = %d, orientation = %s)", delta, orientation)
+	s.mu.Lock()
+	fn := s.onScroll
+	s.mu.Unlock()
+	if fn != nil {
+		s.dispatch("Scroll", func() { fn(delta, orientation) })
+	}
 	return nil
 }
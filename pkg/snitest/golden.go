@@ -0,0 +1,184 @@
+package snitest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The -update flag is defined unless a package initialized earlier has
+// defined it, then Golden follows that one. Test packages that want their
+// own -update should reuse it with flag.Lookup("update") as well.
+func init() {
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "update golden files in testdata")
+	}
+}
+
+// updating reports whether tests run with -update.
+func updating() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	on, _ := getter.Get().(bool)
+	return on
+}
+
+// Golden compares got with testdata/name.golden. With -update flag the
+// file is written instead:
+//
+//	go test ./... -update
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if updating() {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("snitest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("snitest: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("snitest: %v, run tests with -update to create it", err)
+	}
+	if got == string(want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	line := 0
+	for line < len(gotLines) && line < len(wantLines) && gotLines[line] == wantLines[line] {
+		line++
+	}
+	t.Errorf("snitest: %s differs at line %d, run tests with -update to accept\n"+
+		"--- got\n%s\n--- want\n%s", path, line+1, got, want)
+}
+
+// Snapshot returns observable state of item as stable text: properties of
+// the item and its menu. It's meant to be compared with Golden.
+func (h *Harness) Snapshot(item *host.Item) string {
+	h.t.Helper()
+	ctx, cancel := h.context()
+	defer cancel()
+	if err := item.Refresh(ctx); err != nil {
+		h.t.Fatalf("snitest: %v", err)
+	}
+	props := item.Properties()
+	s := FormatProperties(props)
+	if props.Menu != "" {
+		m := h.Menu(item)
+		if err := m.client.Refresh(ctx); err != nil {
+			h.t.Fatalf("snitest: menu of %s: %v", item.ID(), err)
+		}
+		s += "\n" + FormatMenu(m.Root())
+	}
+	return s
+}
+
+// FormatProperties formats StatusNotifierItem properties one per line in
+// the order of host.Properties fields. Pixmaps are hashed.
+func FormatProperties(props host.Properties) string {
+	var b strings.Builder
+	b.WriteString("[item]\n")
+	v := reflect.ValueOf(props)
+	for n := 0; n < v.NumField(); n++ {
+		name := v.Type().Field(n).Name
+		switch value := v.Field(n).Interface().(type) {
+		case []tray.Pixmap:
+			fmt.Fprintf(&b, "%s: %s\n", name, formatPixmaps(value))
+		case tray.ToolTip:
+			tooltip := host.ParseToolTip(value)
+			fmt.Fprintf(&b, "%s.IconName: %q\n", name, tooltip.IconName)
+			fmt.Fprintf(&b, "%s.IconPixmap: %s\n", name, formatPixmaps(tooltip.IconPixmap))
+			fmt.Fprintf(&b, "%s.Title: %q\n", name, tooltip.Title)
+			fmt.Fprintf(&b, "%s.Description: %q\n", name, tooltip.Description)
+		default:
+			if v.Field(n).Kind() == reflect.String {
+				fmt.Fprintf(&b, "%s: %q\n", name, value)
+			} else {
+				fmt.Fprintf(&b, "%s: %v\n", name, value)
+			}
+		}
+	}
+	return b.String()
+}
+
+func formatPixmaps(pixmaps []tray.Pixmap) string {
+	if len(pixmaps) == 0 {
+		return "[]"
+	}
+	parts := make([]string, 0, len(pixmaps))
+	for _, p := range pixmaps {
+		parts = append(parts, fmt.Sprintf("%dx%d %s", p.Width, p.Heigth, hash(p.Data)))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// hash returns a short hash of data, enough to see that it changed.
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// FormatTree formats menu the way FormatMenu does, without a bus.
+func FormatTree(tree menu.ItemTree) (string, error) {
+	root, err := menu.DecodeLayout(tree.ToLayout())
+	if err != nil {
+		return "", err
+	}
+	return FormatMenu(root), nil
+}
+
+// FormatMenu formats menu items one per line with ids and properties
+// sorted by name, children are indented. icon-data is hashed.
+func FormatMenu(root *menu.RemoteItem) string {
+	var b strings.Builder
+	b.WriteString("[menu]\n")
+	formatItem(&b, root, 0)
+	return b.String()
+}
+
+func formatItem(b *strings.Builder, item *menu.RemoteItem, depth int) {
+	names := make([]string, 0, len(item.Properties))
+	for name := range item.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(b, "%s%d", strings.Repeat("  ", depth), item.ID)
+	for _, name := range names {
+		v := item.Properties[name]
+		if data, ok := v.Value().([]byte); ok {
+			fmt.Fprintf(b, " %s=%s", name, hash(data))
+			continue
+		}
+		// In-process layouts have named string types, e.g. menu.ToggleType.
+		if rv := reflect.ValueOf(v.Value()); rv.Kind() == reflect.String {
+			v = dbus.MakeVariant(rv.String())
+		}
+		fmt.Fprintf(b, " %s=%s", name, v.String())
+	}
+	b.WriteByte('\n')
+	for _, child := range item.Children {
+		formatItem(b, child, depth+1)
+	}
+}
@@ -0,0 +1,49 @@
+package snitest_test
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/snitest"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func playerMenu() menu.ItemTree {
+	icon := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	icon.Set(1, 1, color.NRGBA{G: 0xff, A: 0xff})
+	return menu.NewItem().Submenu(
+		menu.NewItem().Label("_Play").IconName("media-playback-start").
+			Shortcut(menu.MustParseShortcut("Ctrl+P")),
+		menu.NewItem().Label("Shuffle").ToggleType(menu.ToggleTypeCheckmark).ToggleState(true),
+		menu.NewItem().Separator(true),
+		menu.NewItem().Label("Output").Submenu(
+			menu.NewItem().Label("Speakers").Icon(icon),
+			menu.NewItem().Label("Headphones").CanBeActivated(false),
+		),
+	).Build()
+}
+
+func TestSnapshot(t *testing.T) {
+	h := snitest.New(t)
+	icon := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	icon.Set(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	app := tray.NewTrayWithConn(h.Conn(), "player", "Player", playerMenu()).
+		SetIconName("audio-volume-high").
+		SetIconPixmap(icon).
+		SetToolTipRaw(tray.ToolTip{Third: "Player", Fourth: "Nothing is playing"})
+	require.NoError(t, app.Setup())
+
+	snapshot := h.Snapshot(h.WaitForItem("player"))
+	snitest.Golden(t, "player", snapshot)
+
+	// The menu looks the same in process and over the bus.
+	tree, err := snitest.FormatTree(playerMenu())
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(snapshot, "\n"+tree), tree)
+}
@@ -0,0 +1,252 @@
+// Package snitest runs tray items against a private session bus in tests.
+//
+// Harness starts dbus-daemon, a StatusNotifierWatcher and a host that sees
+// items the way panels do:
+//
+//	func TestTray(t *testing.T) {
+//		h := snitest.New(t)
+//		app := tray.NewTrayWithConn(h.Conn(), "app", "App", tree)
+//		require.NoError(t, app.Setup())
+//
+//		item := h.WaitForItem("app")
+//		h.Menu(item).Click("File/Quit")
+//		h.WaitForIcon(item, "app-quitting")
+//	}
+//
+// Snapshot and Golden compare the whole state of an item with a file in
+// testdata, so changes of menus show up in reviews:
+//
+//	snitest.Golden(t, "app", h.Snapshot(item))
+//
+// Tests are skipped if dbus-daemon isn't installed.
+package snitest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/host"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultTimeout is how long Harness waits for changes by default.
+const DefaultTimeout = 5 * time.Second
+
+// pollInterval is how often wait checks its condition.
+const pollInterval = 10 * time.Millisecond
+
+// Harness is a private session bus with a watcher and a host. It's
+// stopped when the test finishes.
+type Harness struct {
+	// Timeout limits waiting for changes and D-Bus calls
+	Timeout time.Duration
+
+	t       testing.TB
+	address string
+	host    *host.Host
+	// menuConn is the connection of menu clients, it's nil until Menu
+	menuConn *dbus.Conn
+	// menus caches menus by item id and menu path
+	menus map[string]*Menu
+}
+
+// New starts a private bus, a watcher and a host. The test is skipped if
+// dbus-daemon isn't installed.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	h := &Harness{Timeout: DefaultTimeout, t: t}
+	h.address = bustest.Start(t)
+
+	if err := watcher.New(h.Conn()).Start(); err != nil {
+		t.Fatalf("snitest: couldn't start watcher: %v", err)
+	}
+	h.host = host.New(h.Conn())
+	t.Cleanup(func() { h.host.Close() })
+	ctx, cancel := h.context()
+	defer cancel()
+	if err := h.host.Start(ctx); err != nil {
+		t.Fatalf("snitest: couldn't start host: %v", err)
+	}
+	return h
+}
+
+// Address returns address of the bus, e.g. for DBUS_SESSION_BUS_ADDRESS of
+// a child process.
+func (h *Harness) Address() string {
+	return h.address
+}
+
+// Conn returns a new connection to the bus, it's closed when the test
+// finishes. Use it with tray.NewTrayWithConn.
+func (h *Harness) Conn() *dbus.Conn {
+	h.t.Helper()
+	return bustest.Connect(h.t, h.address)
+}
+
+// Host returns the host, e.g. to receive its events.
+func (h *Harness) Host() *host.Host {
+	return h.host
+}
+
+// Items returns items seen by the host.
+func (h *Harness) Items() []*host.Item {
+	return h.host.Items()
+}
+
+func (h *Harness) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), h.Timeout)
+}
+
+// wait calls cond until it returns true or the timeout passes, then the
+// test fails with msg.
+func (h *Harness) wait(cond func() bool, msg string, args ...interface{}) {
+	h.t.Helper()
+	deadline := time.Now().Add(h.Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("snitest: "+msg, args...)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// WaitForItem waits until an item with Id property id is registered.
+func (h *Harness) WaitForItem(id string) *host.Item {
+	h.t.Helper()
+	var found *host.Item
+	h.wait(func() bool {
+		for _, item := range h.Items() {
+			if item.Properties().Id == id {
+				found = item
+				return true
+			}
+		}
+		return false
+	}, "no item %q", id)
+	return found
+}
+
+// WaitForRemoved waits until item is unregistered.
+func (h *Harness) WaitForRemoved(item *host.Item) {
+	h.t.Helper()
+	h.wait(func() bool {
+		for _, other := range h.Items() {
+			if other == item {
+				return false
+			}
+		}
+		return true
+	}, "item %s wasn't removed", item.ID())
+}
+
+// WaitFor waits until properties of item satisfy cond and returns them.
+func (h *Harness) WaitFor(item *host.Item, cond func(host.Properties) bool) host.Properties {
+	h.t.Helper()
+	var props host.Properties
+	h.wait(func() bool {
+		props = item.Properties()
+		return cond(props)
+	}, "properties of %s didn't change, last: %+v", item.ID(), &props)
+	return props
+}
+
+// WaitForIcon waits until IconName of item is name.
+func (h *Harness) WaitForIcon(item *host.Item, name string) {
+	h.t.Helper()
+	h.WaitFor(item, func(p host.Properties) bool { return p.IconName == name })
+}
+
+// WaitForTitle waits until Title of item is title.
+func (h *Harness) WaitForTitle(item *host.Item, title string) {
+	h.t.Helper()
+	h.WaitFor(item, func(p host.Properties) bool { return p.Title == title })
+}
+
+// Menu returns menu of item. The test fails if the item has no menu. Menus
+// are kept in sync until the test finishes, the same Menu is returned for
+// the item as long as its menu path doesn't change.
+func (h *Harness) Menu(item *host.Item) *Menu {
+	h.t.Helper()
+	path := item.Properties().Menu
+	if path == "" {
+		h.t.Fatalf("snitest: item %s has no menu", item.ID())
+	}
+	key := item.ID() + "\x00" + string(path)
+	if m, ok := h.menus[key]; ok {
+		return m
+	}
+	if h.menuConn == nil {
+		h.menuConn = h.Conn()
+		h.menus = make(map[string]*Menu)
+	}
+	client := menu.NewClient(h.menuConn, item.Service(), path)
+	ctx, cancel := h.context()
+	defer cancel()
+	if err := client.Start(ctx); err != nil {
+		h.t.Fatalf("snitest: menu of %s: %v", item.ID(), err)
+	}
+	h.t.Cleanup(func() { client.Close() })
+	m := &Menu{h: h, client: client}
+	h.menus[key] = m
+	return m
+}
+
+// Menu is a menu of an item as seen by the host.
+type Menu struct {
+	h      *Harness
+	client *menu.Client
+}
+
+// Client returns the underlying client.
+func (m *Menu) Client() *menu.Client {
+	return m.client
+}
+
+// Root returns the current layout.
+func (m *Menu) Root() *menu.RemoteItem {
+	return m.client.Root()
+}
+
+// Item returns item by labels separated by slashes, e.g. "File/Quit", see
+// menu.RemoteItem.FindLabel. The layout is fetched first, so changes made
+// before the call are seen. The test fails if there is no such item.
+func (m *Menu) Item(path string) *menu.RemoteItem {
+	m.h.t.Helper()
+	ctx, cancel := m.h.context()
+	defer cancel()
+	if err := m.client.Refresh(ctx); err != nil {
+		m.h.t.Fatalf("snitest: %v", err)
+	}
+	item, ok := m.client.Root().FindLabel(strings.Split(path, "/")...)
+	if !ok {
+		m.h.t.Fatalf("snitest: no menu item %q", path)
+	}
+	return item
+}
+
+// Click clicks item by labels separated by slashes, e.g. "File/Quit". The
+// test fails if the item is missing or disabled, or the click fails.
+func (m *Menu) Click(path string) {
+	m.h.t.Helper()
+	item := m.Item(path)
+	if !item.Enabled {
+		m.h.t.Fatalf("snitest: menu item %q is disabled", path)
+	}
+	ctx, cancel := m.h.context()
+	defer cancel()
+	if err := m.client.Click(ctx, item.ID); err != nil {
+		m.h.t.Fatalf("snitest: click %q: %v", path, err)
+	}
+}
+
+// WaitFor waits until the layout satisfies cond.
+func (m *Menu) WaitFor(cond func(root *menu.RemoteItem) bool) {
+	m.h.t.Helper()
+	m.h.wait(func() bool { return cond(m.client.Root()) }, "menu didn't change")
+}
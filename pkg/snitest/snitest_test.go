@@ -0,0 +1,51 @@
+package snitest_test
+
+import (
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/snitest"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarness(t *testing.T) {
+	assert := assert.New(t)
+	h := snitest.New(t)
+
+	var app *tray.Tray
+	muted := menu.NewValue(false)
+	tree := menu.NewItem().Submenu(
+		menu.NewItem().Label("_Sound").Submenu(
+			menu.NewItem().Label("Mute").ToggleType(menu.ToggleTypeCheckmark).
+				ToggleStateFrom(muted).
+				OnClick(func() {
+					muted.Set(!muted.Get())
+					app.SetIconName("audio-volume-muted")
+					app.SignalNewIcon()
+				}),
+		),
+	).Build()
+	app = tray.NewTrayWithConn(h.Conn(), "player", "Player", tree).
+		SetIconName("audio-volume-high")
+	require.NoError(t, app.Setup())
+
+	item := h.WaitForItem("player")
+	assert.Len(h.Items(), 1)
+	assert.Equal("audio-volume-high", item.Properties().IconName)
+
+	m := h.Menu(item)
+	assert.Same(m, h.Menu(item), "menus are cached")
+	assert.Equal(int32(0), m.Item("Sound/Mute").ToggleState)
+	m.Click("Sound/Mute")
+	h.WaitForIcon(item, "audio-volume-muted")
+	m.WaitFor(func(root *menu.RemoteItem) bool {
+		mute, ok := root.FindLabel("Sound", "Mute")
+		return ok && mute.ToggleState == 1
+	})
+
+	require.NoError(t, app.Close())
+	h.WaitForRemoved(item)
+}
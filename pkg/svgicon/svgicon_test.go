@@ -0,0 +1,40 @@
+package svgicon_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/svgicon"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const wideRect = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10">
+<rect x="0" y="0" width="20" height="10" fill="#ff0000"/>
+</svg>`
+
+func TestRasterize(t *testing.T) {
+	assert := assert.New(t)
+	icon, err := svgicon.Parse([]byte(wideRect))
+	require.NoError(t, err)
+
+	w, h := icon.Size()
+	assert.Equal(20, w)
+	assert.Equal(10, h)
+
+	img := icon.Square(32)
+	assert.Equal(image.Rect(0, 0, 32, 32), img.Bounds())
+	// the icon is centered vertically: rows 8..24 are filled
+	assert.Equal(color.RGBA{}, img.RGBAAt(16, 4))
+	assert.Equal(color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(16, 16))
+	assert.Equal(color.RGBA{}, img.RGBAAt(16, 28))
+}
+
+func TestParseError(t *testing.T) {
+	_, err := svgicon.Parse([]byte("<svg"))
+	assert.Error(t, err)
+	_, err = svgicon.Parse([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+	assert.Error(t, err)
+}
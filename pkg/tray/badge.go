@@ -0,0 +1,245 @@
+package tray
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// Corner selects a corner of the icon where a badge is drawn.
+type Corner int
+
+const (
+	CornerTopRight Corner = iota
+	CornerTopLeft
+	CornerBottomRight
+	CornerBottomLeft
+)
+
+// Badge is a small marker drawn on top of the icon, for example a number of
+// unread messages or a coloured dot.
+type Badge struct {
+	// Text is drawn inside of the badge. The built-in font supports only
+	// digits, '+' and '!'; other characters are skipped. Empty Text draws a
+	// plain dot.
+	Text string
+	// Corner is where the badge is placed. Default is top right.
+	Corner Corner
+	// Background is a fill color of the badge. Default is red.
+	Background color.Color
+	// Foreground is a color of the Text. Default is white.
+	Foreground color.Color
+
+	// hidden badge draws nothing, see CountBadge.
+	hidden bool
+}
+
+// CountBadge returns badge that displays n. Numbers greater than 99 are
+// displayed as "99+". For n <= 0 it returns a badge that draws nothing, so
+// the icon looks as if there was no badge.
+func CountBadge(n int) Badge {
+	if n <= 0 {
+		return Badge{hidden: true}
+	}
+	text := strconv.Itoa(n)
+	if n > 99 {
+		text = "99+"
+	}
+	return Badge{Text: text}
+}
+
+// DrawBadge composites b onto a copy of base. The result has the same size as
+// base and its bounds start at (0, 0).
+func DrawBadge(base image.Image, b Badge) *image.RGBA {
+	bounds := base.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), base, bounds.Min, draw.Src)
+	drawBadge(dst, b)
+	return dst
+}
+
+// SetIconBadge draws b onto base and sets result as IconPixmap. Use it for
+// hosts that ignore OverlayIconPixmap.
+//
+// Note: see SetOverlayBadge
+func (t *Tray) SetIconBadge(base image.Image, b Badge) *Tray {
+	t.setSniProp("IconPixmap", []Pixmap{
+		imageToArgb32(DrawBadge(base, b)),
+	})
+	return t
+}
+
+// SetOverlayBadge draws b onto a transparent image of the same size as base
+// and sets result as OverlayIconPixmap. Base itself is not drawn, it's only
+// used to size the overlay.
+//
+// Note: see SetIconBadge
+func (t *Tray) SetOverlayBadge(base image.Image, b Badge) *Tray {
+	bounds := base.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	drawBadge(dst, b)
+	t.setSniProp("OverlayIconPixmap", []Pixmap{
+		imageToArgb32(dst),
+	})
+	return t
+}
+
+func drawBadge(dst *image.RGBA, b Badge) {
+	if b.hidden {
+		return
+	}
+	bg, fg := b.Background, b.Foreground
+	if bg == nil {
+		bg = color.RGBA{R: 0xe5, G: 0x39, B: 0x35, A: 0xff}
+	}
+	if fg == nil {
+		fg = color.White
+	}
+
+	size := dst.Bounds().Dx()
+	if h := dst.Bounds().Dy(); h < size {
+		size = h
+	}
+	if size == 0 {
+		return
+	}
+
+	glyphs := make([]glyph, 0, len(b.Text))
+	for _, r := range b.Text {
+		if g, ok := badgeFont[r]; ok {
+			glyphs = append(glyphs, g)
+		}
+	}
+
+	var width, height, scale int
+	if len(glyphs) == 0 {
+		width = maxInt(size*3/8, 2)
+		height = width
+	} else {
+		height = maxInt(size/2, glyphHeight+2)
+		if height > size {
+			height = size
+		}
+		textWidth := len(glyphs)*(glyphWidth+1) - 1
+		// Shrink text until the badge fits into the icon.
+		for scale = maxInt(height*2/3/glyphHeight, 1); scale > 1; scale-- {
+			if badgeWidth(textWidth*scale, height) <= size {
+				break
+			}
+		}
+		width = minInt(badgeWidth(textWidth*scale, height), size)
+	}
+
+	rect := badgeRect(dst.Bounds(), b.Corner, width, height)
+	draw.DrawMask(dst, rect, image.NewUniform(bg), image.Point{},
+		pillMask(width, height), image.Point{}, draw.Over)
+	if len(glyphs) == 0 {
+		return
+	}
+
+	textWidth := (len(glyphs)*(glyphWidth+1) - 1) * scale
+	textHeight := glyphHeight * scale
+	origin := image.Pt(
+		rect.Min.X+(width-textWidth+1)/2,
+		rect.Min.Y+(height-textHeight+1)/2,
+	)
+	text := image.NewUniform(fg)
+	for i, g := range glyphs {
+		x0 := origin.X + i*(glyphWidth+1)*scale
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if !g.set(col, row) {
+					continue
+				}
+				px := image.Rect(x0+col*scale, origin.Y+row*scale,
+					x0+(col+1)*scale, origin.Y+(row+1)*scale)
+				draw.Draw(dst, px, text, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
+
+// badgeWidth returns width of a pill of the given height that fits text with
+// horizontal padding equal to the vertical one.
+func badgeWidth(textWidth, height int) int {
+	return maxInt(textWidth+height/2, height)
+}
+
+func badgeRect(bounds image.Rectangle, c Corner, width, height int) image.Rectangle {
+	var p image.Point
+	switch c {
+	case CornerTopLeft:
+		p = bounds.Min
+	case CornerBottomRight:
+		p = image.Pt(bounds.Max.X-width, bounds.Max.Y-height)
+	case CornerBottomLeft:
+		p = image.Pt(bounds.Min.X, bounds.Max.Y-height)
+	default:
+		p = image.Pt(bounds.Max.X-width, bounds.Min.Y)
+	}
+	return image.Rectangle{Min: p, Max: p.Add(image.Pt(width, height))}
+}
+
+// pillMask returns anti-aliased mask of a rectangle with fully rounded ends.
+// When width equals height it's a circle.
+func pillMask(width, height int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	r := float64(height) / 2
+	left, right := r, float64(width)-r
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			cx := math.Max(left, math.Min(px, right))
+			d := math.Hypot(px-cx, py-r)
+			a := math.Max(0, math.Min(1, r-d+0.5))
+			mask.SetAlpha(x, y, color.Alpha{A: uint8(a * 0xff)})
+		}
+	}
+	return mask
+}
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+// glyph is a 3x5 bitmap, each row uses 3 lower bits, the most significant bit
+// is the leftmost pixel.
+type glyph [glyphHeight]uint8
+
+func (g glyph) set(col, row int) bool {
+	return g[row]&(1<<(glyphWidth-1-col)) != 0
+}
+
+// badgeFont is a built-in bitmap font, so no font files are needed to draw
+// badges.
+var badgeFont = map[rune]glyph{
+	'0': {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1': {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2': {0b111, 0b001, 0b111, 0b100, 0b111},
+	'3': {0b111, 0b001, 0b111, 0b001, 0b111},
+	'4': {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5': {0b111, 0b100, 0b111, 0b001, 0b111},
+	'6': {0b111, 0b100, 0b111, 0b101, 0b111},
+	'7': {0b111, 0b001, 0b001, 0b001, 0b001},
+	'8': {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9': {0b111, 0b101, 0b111, 0b001, 0b111},
+	'+': {0b000, 0b010, 0b111, 0b010, 0b000},
+	'!': {0b010, 0b010, 0b010, 0b000, 0b010},
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
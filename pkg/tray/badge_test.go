@@ -0,0 +1,60 @@
+package tray_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var blue = color.RGBA{B: 0xff, A: 0xff}
+
+// blueIcon returns a solid icon with bounds not starting at (0, 0).
+func blueIcon(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(10, 10, 10+size, 10+size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+	return img
+}
+
+func TestDrawBadge(t *testing.T) {
+	tests := []struct {
+		corner tray.Corner
+		badge  image.Point
+		plain  image.Point
+	}{
+		{tray.CornerTopRight, image.Pt(28, 4), image.Pt(4, 28)},
+		{tray.CornerTopLeft, image.Pt(4, 4), image.Pt(28, 28)},
+		{tray.CornerBottomRight, image.Pt(28, 28), image.Pt(4, 4)},
+		{tray.CornerBottomLeft, image.Pt(4, 28), image.Pt(28, 4)},
+	}
+	for _, tt := range tests {
+		base := blueIcon(32)
+		got := tray.DrawBadge(base, tray.Badge{Corner: tt.corner})
+
+		assert.Equal(t, image.Rect(0, 0, 32, 32), got.Bounds(), "corner %d", tt.corner)
+		assert.NotEqual(t, blue, got.RGBAAt(tt.badge.X, tt.badge.Y), "corner %d", tt.corner)
+		assert.Equal(t, blue, got.RGBAAt(tt.plain.X, tt.plain.Y), "corner %d", tt.corner)
+		assert.Equal(t, blueIcon(32).Pix, base.Pix, "base must not be changed")
+	}
+}
+
+func TestCountBadge(t *testing.T) {
+	base := blueIcon(32)
+	assert.Equal(t,
+		tray.DrawBadge(base, tray.Badge{Text: "99+"}).Pix,
+		tray.DrawBadge(base, tray.CountBadge(150)).Pix)
+	assert.NotEqual(t,
+		tray.DrawBadge(base, tray.Badge{Text: "99"}).Pix,
+		tray.DrawBadge(base, tray.CountBadge(150)).Pix)
+	assert.Equal(t,
+		tray.DrawBadge(base, tray.Badge{Text: "7"}).Pix,
+		tray.DrawBadge(base, tray.CountBadge(7)).Pix)
+
+	for _, n := range []int{0, -3} {
+		assert.Equal(t, blueIcon(32).Pix, tray.DrawBadge(base, tray.CountBadge(n)).Pix, "n = %d", n)
+	}
+}
@@ -1,6 +1,7 @@
 package tray
 
 import (
+	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/prop"
 	"github.com/knightpp/sni/pkg/sni"
 )
@@ -20,7 +21,7 @@ func makePropsSni(id, title string) map[string]*prop.Prop {
 			Value: sni.StatusActive,
 		},
 		"WindowId": {
-			Value: 0,
+			Value: int32(0),
 		},
 		"IconName": {
 			Value: "face-cool",
@@ -50,7 +51,7 @@ func makePropsSni(id, title string) map[string]*prop.Prop {
 			Value: false,
 		},
 		"Menu": {
-			Value: MENU_PATH,
+			Value: dbus.ObjectPath(MENU_PATH),
 		},
 		"IconThemePath": {
 			Value: "",
@@ -66,13 +67,16 @@ func makePropsMenu() map[string]*prop.Prop {
 			Value: uint32(3),
 		},
 		"TextDirection": {
-			Value: "ltr",
+			Value: DetectTextDirection(),
+			Emit:  prop.EmitTrue,
 		},
 		"Status": {
-			Value: "normal",
+			Value: MenuStatusNormal,
+			Emit:  prop.EmitTrue,
 		},
 		"IconThemePath": {
 			Value: []string{},
+			Emit:  prop.EmitTrue,
 		},
 	}
 }
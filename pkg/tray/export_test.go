@@ -0,0 +1,25 @@
+package tray
+
+import "time"
+
+// SetFallbackPollInterval changes how often fallback watchers of trays
+// created afterwards look for queued watchers. It returns a function that
+// restores the previous interval.
+func SetFallbackPollInterval(d time.Duration) (restore func()) {
+	prev := fallbackPollInterval
+	fallbackPollInterval = d
+	return func() { fallbackPollInterval = prev }
+}
+
+// SniProp returns StatusNotifierItem property value.
+func (t *Tray) SniProp(name string) interface{} {
+	return t.getSniProp(name)
+}
+
+// MenuStatus returns menu status, it's safe to call while SetMenuStatusFor
+// timer may fire.
+func (t *Tray) MenuStatus() MenuStatus {
+	t.menuStatusMu.Lock()
+	defer t.menuStatusMu.Unlock()
+	return t.getMenuProp("Status").(MenuStatus)
+}
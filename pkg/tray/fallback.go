@@ -0,0 +1,110 @@
+package tray
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/knightpp/sni/generated/d_bus"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fallbackPollInterval is how often the fallback watcher checks whether a
+// real watcher waits for the name. Trays copy it when they are created.
+var fallbackPollInterval = 2 * time.Second
+
+// SetFallbackWatcher makes the tray run an in-process StatusNotifierWatcher
+// while there is none on the bus, so the icon shows up as soon as a host
+// connects. It should be called before Setup.
+//
+// The fallback watcher yields the name to a real watcher that replaces it
+// or queues for the name, and the tray registers with the new watcher.
+// Other items can register with the fallback watcher too.
+func (t *Tray) SetFallbackWatcher(enable bool) *Tray {
+	t.fallbackMu.Lock()
+	defer t.fallbackMu.Unlock()
+	t.fallbackEnabled = enable
+	return t
+}
+
+// ensureWatcher starts the fallback watcher if it's enabled and nobody owns
+// watcher name.
+func (t *Tray) ensureWatcher() error {
+	t.fallbackMu.Lock()
+	defer t.fallbackMu.Unlock()
+	if !t.fallbackEnabled || t.fallback != nil {
+		return nil
+	}
+	bus := d_bus.NewDBus(t.conn.BusObject())
+	hasOwner, err := bus.NameHasOwner(context.Background(), watcher.Name)
+	if err != nil || hasOwner {
+		return err
+	}
+
+	w := watcher.New(t.conn)
+	if err := w.Export(); err != nil {
+		return err
+	}
+	reply, err := t.conn.RequestName(watcher.Name,
+		dbus.NameFlagAllowReplacement|dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		// Another watcher was quicker.
+		w.Close()
+		return err
+	}
+	log.Print("started fallback StatusNotifierWatcher")
+	t.fallback = w
+	stop := make(chan struct{})
+	t.stopFallbackPoll = func() { close(stop) }
+	go t.pollQueuedWatchers(stop)
+	return nil
+}
+
+// pollQueuedWatchers yields the name when a real watcher queues for it.
+// Queued owners aren't announced by the bus, so it has to poll.
+func (t *Tray) pollQueuedWatchers(stop <-chan struct{}) {
+	ticker := time.NewTicker(t.fallbackPollInterval)
+	defer ticker.Stop()
+	bus := d_bus.NewDBus(t.conn.BusObject())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		owners, err := bus.ListQueuedOwners(context.Background(), watcher.Name)
+		if err != nil {
+			if !t.conn.Connected() {
+				return
+			}
+			log.Print("couldn't list queued watchers: ", err)
+			continue
+		}
+		if len(owners) > 1 {
+			t.stopFallback()
+			return
+		}
+	}
+}
+
+// stopFallback stops the fallback watcher and releases its name, the name
+// goes to the next queued owner.
+func (t *Tray) stopFallback() {
+	t.fallbackMu.Lock()
+	defer t.fallbackMu.Unlock()
+	if t.fallback == nil {
+		return
+	}
+	t.stopFallbackPoll()
+	// The watcher doesn't release the name it hasn't requested itself.
+	if _, err := t.conn.ReleaseName(watcher.Name); err != nil {
+		log.Print("couldn't release StatusNotifierWatcher name: ", err)
+	}
+	if err := t.fallback.Close(); err != nil {
+		log.Print("couldn't stop fallback StatusNotifierWatcher: ", err)
+	}
+	t.fallback = nil
+	log.Print("stopped fallback StatusNotifierWatcher")
+}
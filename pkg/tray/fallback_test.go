@@ -0,0 +1,43 @@
+package tray_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/tray"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackWatcher(t *testing.T) {
+	address := bustest.Start(t)
+	t.Cleanup(tray.SetFallbackPollInterval(20 * time.Millisecond))
+
+	conn, err := dbus.Connect(address)
+	require.NoError(t, err)
+	tr := tray.NewTrayWithConn(conn, "test", "Test", menu.NewItem().Build()).
+		SetFallbackWatcher(true)
+	require.NoError(t, tr.Setup())
+	defer tr.Close()
+
+	// A real watcher queues for the name and the fallback one yields.
+	other, err := dbus.Connect(address)
+	require.NoError(t, err)
+	defer other.Close()
+	queued := watcher.New(other)
+	require.NoError(t, queued.Export())
+	reply, err := other.RequestName(watcher.Name, 0)
+	require.NoError(t, err)
+	assert.Equal(t, dbus.RequestNameReplyInQueue, reply)
+
+	assert.Eventually(t, func() bool {
+		items := queued.Items()
+		return len(items) == 1 && strings.HasPrefix(items[0], "org.kde.StatusNotifierItem-")
+	}, 2*time.Second, 10*time.Millisecond)
+}
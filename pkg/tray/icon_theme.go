@@ -0,0 +1,97 @@
+package tray
+
+import (
+	"errors"
+	"log"
+
+	"github.com/knightpp/sni/pkg/icontheme"
+)
+
+// SetIconTheme enables resolving of IconName, OverlayIconName and
+// AttentionIconName. Once set, every icon name is looked up in theme and in
+// IconThemePath, and the found image is published as a matching pixmap
+// property alongside the name, so hosts that cannot resolve themed names
+// still show the icon. Pass nil to disable.
+//
+// Names that are already set are resolved immediately. Pixmaps set by
+// resolving are cleared when the name isn't found or the theme is disabled,
+// pixmaps set explicitly are kept.
+func (t *Tray) SetIconTheme(theme *icontheme.Theme) *Tray {
+	t.iconTheme = theme
+	t.resolveIcons()
+	return t
+}
+
+// SetIconThemePath sets StatusNotifierItem IconThemePath property. It's
+// an additional directory hosts and SetIconTheme search for icons, names
+// that are already set are resolved again.
+func (t *Tray) SetIconThemePath(path string) *Tray {
+	t.setSniProp("IconThemePath", path)
+	t.resolveIcons()
+	return t
+}
+
+// iconNameToPixmap maps icon name props to their pixmap counterparts.
+var iconNameToPixmap = map[string]string{
+	"IconName":          "IconPixmap",
+	"OverlayIconName":   "OverlayIconPixmap",
+	"AttentionIconName": "AttentionIconPixmap",
+}
+
+// resolveIcons resolves all icon names.
+func (t *Tray) resolveIcons() {
+	for name, pixmap := range iconNameToPixmap {
+		s, _ := t.getSniProp(name).(string)
+		t.resolveIcon(s, pixmap)
+	}
+}
+
+// resolveIcon looks up name in the icon theme and sets pixmapProp to the
+// found images. If the icon isn't found or icon theme is not set, a pixmap
+// set by resolving is cleared and one set explicitly is kept.
+func (t *Tray) resolveIcon(name, pixmapProp string) {
+	pixmaps := t.lookupIcon(name)
+	if len(pixmaps) == 0 {
+		if t.resolvedPixmaps[pixmapProp] {
+			t.setSniProp(pixmapProp, []Pixmap{})
+		}
+		return
+	}
+	t.setSniProp(pixmapProp, pixmaps)
+	if t.resolvedPixmaps == nil {
+		t.resolvedPixmaps = make(map[string]bool)
+	}
+	t.resolvedPixmaps[pixmapProp] = true
+}
+
+// lookupIcon returns images of name at pixmapSizes, it returns nil if icon
+// theme is not set or the icon isn't found.
+func (t *Tray) lookupIcon(name string) []Pixmap {
+	if t.iconTheme == nil || name == "" {
+		return nil
+	}
+	var extraDirs []string
+	if dir, _ := t.getSniProp("IconThemePath").(string); dir != "" {
+		extraDirs = append(extraDirs, dir)
+	}
+
+	var pixmaps []Pixmap
+	seen := make(map[string]bool)
+	for _, size := range pixmapSizes {
+		path, err := t.iconTheme.Lookup(name, size, extraDirs...)
+		if errors.Is(err, icontheme.ErrNotFound) {
+			break
+		}
+		if err != nil || seen[path] {
+			continue
+		}
+		seen[path] = true
+		img, err := icontheme.LoadSized(path, size)
+		if err != nil {
+			log.Printf("couldn't load icon %q: %v", path, err)
+			continue
+		}
+		pixmaps = append(pixmaps, imageToArgb32(img))
+	}
+	return pixmaps
+}
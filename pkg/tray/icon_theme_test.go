@@ -0,0 +1,69 @@
+package tray_test
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knightpp/sni/pkg/icontheme"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePng(t *testing.T, path string, size int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, size, size))))
+}
+
+func pixmapSizes(v interface{}) []int32 {
+	var sizes []int32
+	for _, p := range v.([]tray.Pixmap) {
+		sizes = append(sizes, p.Width)
+	}
+	return sizes
+}
+
+func TestSetIconTheme(t *testing.T) {
+	assert := assert.New(t)
+	base := t.TempDir()
+	extra := t.TempDir()
+	writePng(t, filepath.Join(base, "app.png"), 16)
+	writePng(t, filepath.Join(extra, "extra.png"), 22)
+
+	tr := tray.NewTrayWithConn(nil, "test", "Test", menu.NewItem().Build())
+	// Names set before the theme are resolved by SetIconTheme.
+	tr.SetIconName("app")
+	tr.SetIconTheme(icontheme.New("none", base))
+	assert.Equal([]int32{16}, pixmapSizes(tr.SniProp("IconPixmap")))
+
+	// A missing icon keeps the pixmap that was set explicitly.
+	tr.SetOverlayIconPixmap(image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	tr.SetOverlayIconName("extra")
+	assert.Equal([]int32{8}, pixmapSizes(tr.SniProp("OverlayIconPixmap")))
+
+	// Names are resolved again when IconThemePath changes.
+	tr.SetIconThemePath(extra)
+	assert.Equal([]int32{22}, pixmapSizes(tr.SniProp("OverlayIconPixmap")))
+
+	// A missing icon clears the pixmap resolved for the previous name.
+	tr.SetIconName("missing")
+	assert.Empty(pixmapSizes(tr.SniProp("IconPixmap")))
+	tr.SetIconName("app")
+	assert.Equal([]int32{16}, pixmapSizes(tr.SniProp("IconPixmap")))
+
+	// Disabling the theme clears resolved pixmaps only.
+	tr.SetAttentionIconPixmap(image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	tr.SetIconTheme(nil)
+	assert.Empty(pixmapSizes(tr.SniProp("IconPixmap")))
+	assert.Empty(pixmapSizes(tr.SniProp("OverlayIconPixmap")))
+	assert.Equal([]int32{8}, pixmapSizes(tr.SniProp("AttentionIconPixmap")))
+}
@@ -0,0 +1,63 @@
+package tray
+
+import (
+	"strings"
+	"time"
+
+	"github.com/knightpp/sni/pkg/i18n"
+)
+
+// rtlLanguages are ISO 639 codes of languages written right to left.
+var rtlLanguages = map[string]bool{
+	"ar": true, "arc": true, "ckb": true, "dv": true, "fa": true,
+	"he": true, "iw": true, "ks": true, "ku": true, "ps": true,
+	"sd": true, "syr": true, "ug": true, "ur": true, "yi": true,
+}
+
+// DetectTextDirection returns text direction of the locale messages are
+// translated to, see i18n.Locale.
+func DetectTextDirection() TextDirection {
+	return TextDirectionOf(i18n.Locale())
+}
+
+// TextDirectionOf returns text direction of locale such as "he_IL.UTF-8".
+func TextDirectionOf(locale string) TextDirection {
+	lang := locale
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if rtlLanguages[strings.ToLower(lang)] {
+		return TextDirectionRTL
+	}
+	return TextDirectionLTR
+}
+
+// SetMenuStatusFor sets menu status for d and then restores the status that
+// was set before. Calling SetMenuStatus meanwhile cancels restoring. It's
+// handy to draw attention to the menu with MenuStatusNotice for a while.
+//
+// The status is restored from another goroutine, so the tray should be set
+// up by then.
+func (t *Tray) SetMenuStatusFor(status MenuStatus, d time.Duration) *Tray {
+	t.menuStatusMu.Lock()
+	defer t.menuStatusMu.Unlock()
+	if t.menuStatusTimer != nil {
+		t.menuStatusTimer.Stop()
+	} else {
+		t.menuStatusBase, _ = t.getMenuProp("Status").(MenuStatus)
+	}
+	t.setMenuProp("Status", status)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		t.menuStatusMu.Lock()
+		defer t.menuStatusMu.Unlock()
+		if t.menuStatusTimer != timer {
+			return
+		}
+		t.menuStatusTimer = nil
+		t.setMenuProp("Status", t.menuStatusBase)
+	})
+	t.menuStatusTimer = timer
+	return t
+}
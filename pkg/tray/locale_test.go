@@ -0,0 +1,48 @@
+package tray_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextDirection(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(tray.TextDirectionRTL, tray.TextDirectionOf("he_IL.UTF-8"))
+	assert.Equal(tray.TextDirectionRTL, tray.TextDirectionOf("ar"))
+	assert.Equal(tray.TextDirectionLTR, tray.TextDirectionOf("en_US.UTF-8"))
+	assert.Equal(tray.TextDirectionLTR, tray.TextDirectionOf("C"))
+
+	t.Setenv("LANGUAGE", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "fa_IR.UTF-8")
+	t.Setenv("LANG", "en_US.UTF-8")
+	assert.Equal(tray.TextDirectionRTL, tray.DetectTextDirection())
+
+	// LANGUAGE wins as it does for translations.
+	t.Setenv("LANGUAGE", "en_GB:fa")
+	assert.Equal(tray.TextDirectionLTR, tray.DetectTextDirection())
+}
+
+func TestSetMenuStatusFor(t *testing.T) {
+	assert := assert.New(t)
+	tr := tray.NewTrayWithConn(nil, "test", "Test", menu.NewItem().Build())
+
+	tr.SetMenuStatusFor(tray.MenuStatusNotice, 20*time.Millisecond)
+	// A nested call keeps the status to restore.
+	tr.SetMenuStatusFor(tray.MenuStatusNotice, 20*time.Millisecond)
+	assert.Equal(tray.MenuStatusNotice, tr.MenuStatus())
+	assert.Eventually(func() bool {
+		return tr.MenuStatus() == tray.MenuStatusNormal
+	}, time.Second, 5*time.Millisecond)
+
+	// Setting status cancels restoring.
+	tr.SetMenuStatusFor(tray.MenuStatusNotice, 20*time.Millisecond)
+	tr.SetMenuStatus(tray.MenuStatusNotice)
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(tray.MenuStatusNotice, tr.MenuStatus())
+}
@@ -0,0 +1,50 @@
+package tray
+
+import (
+	"github.com/knightpp/sni/pkg/svgicon"
+)
+
+// SetIconSVG rasterises icon at common panel sizes and sets StatusNotifierItem
+// IconPixmap property.
+func (t *Tray) SetIconSVG(icon *svgicon.Icon) *Tray {
+	t.setSniProp("IconPixmap", svgToPixmaps(icon))
+	return t
+}
+
+// SetOverlayIconSVG rasterises icon at common panel sizes and sets
+// StatusNotifierItem OverlayIconPixmap property.
+func (t *Tray) SetOverlayIconSVG(icon *svgicon.Icon) *Tray {
+	t.setSniProp("OverlayIconPixmap", svgToPixmaps(icon))
+	return t
+}
+
+// SetAttentionIconSVG rasterises icon at common panel sizes and sets
+// StatusNotifierItem AttentionIconPixmap property.
+func (t *Tray) SetAttentionIconSVG(icon *svgicon.Icon) *Tray {
+	t.setSniProp("AttentionIconPixmap", svgToPixmaps(icon))
+	return t
+}
+
+// SetToolTipIconSVG rasterises icon at common panel sizes and sets it as
+// an icon of the ToolTip property. Other fields of the tooltip are kept.
+func (t *Tray) SetToolTipIconSVG(icon *svgicon.Icon) *Tray {
+	tooltip, _ := t.getSniProp("ToolTip").(ToolTip)
+	tooltip.Second = tooltip.Second[:0:0]
+	for _, p := range svgToPixmaps(icon) {
+		tooltip.Second = append(tooltip.Second, struct {
+			First  int32
+			Second int32
+			Third  []byte
+		}{p.Width, p.Heigth, p.Data})
+	}
+	t.setSniProp("ToolTip", tooltip)
+	return t
+}
+
+func svgToPixmaps(icon *svgicon.Icon) []Pixmap {
+	pixmaps := make([]Pixmap, 0, len(pixmapSizes))
+	for _, size := range pixmapSizes {
+		pixmaps = append(pixmaps, imageToArgb32(icon.Square(size)))
+	}
+	return pixmaps
+}
@@ -0,0 +1,65 @@
+package tray_test
+
+import (
+	"testing"
+
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/svgicon"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redSquare = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+<rect x="0" y="0" width="10" height="10" fill="#ff0000"/>
+</svg>`
+
+var panelSizes = []int32{16, 22, 24, 32, 48, 64}
+
+func TestSetIconSVG(t *testing.T) {
+	icon, err := svgicon.Parse([]byte(redSquare))
+	require.NoError(t, err)
+
+	tests := []struct {
+		prop string
+		set  func(*tray.Tray, *svgicon.Icon) *tray.Tray
+	}{
+		{"IconPixmap", (*tray.Tray).SetIconSVG},
+		{"OverlayIconPixmap", (*tray.Tray).SetOverlayIconSVG},
+		{"AttentionIconPixmap", (*tray.Tray).SetAttentionIconSVG},
+	}
+	for _, tt := range tests {
+		tr := tray.NewTrayWithConn(nil, "test", "Test", menu.NewItem().Build())
+		tt.set(tr, icon)
+
+		pixmaps := tr.SniProp(tt.prop).([]tray.Pixmap)
+		assert.Equal(t, panelSizes, pixmapSizes(pixmaps), tt.prop)
+		for _, p := range pixmaps {
+			assert.Equal(t, p.Width, p.Heigth, tt.prop)
+			// Opaque red in ARGB32.
+			assert.Equal(t, []byte{0xff, 0xff, 0, 0}, p.Data[:4], tt.prop)
+		}
+	}
+}
+
+func TestSetToolTipIconSVG(t *testing.T) {
+	icon, err := svgicon.Parse([]byte(redSquare))
+	require.NoError(t, err)
+
+	tr := tray.NewTrayWithConn(nil, "test", "Test", menu.NewItem().Build())
+	tr.SetToolTipRaw(tray.ToolTip{First: "icon", Third: "Title", Fourth: "Description"})
+	tr.SetToolTipIconSVG(icon)
+
+	tooltip := tr.SniProp("ToolTip").(tray.ToolTip)
+	assert.Equal(t, "icon", tooltip.First)
+	assert.Equal(t, "Title", tooltip.Third)
+	assert.Equal(t, "Description", tooltip.Fourth)
+	var sizes []int32
+	for _, p := range tooltip.Second {
+		assert.Equal(t, p.First, p.Second)
+		assert.Len(t, p.Third, int(p.First*p.Second*4))
+		sizes = append(sizes, p.First)
+	}
+	assert.Equal(t, panelSizes, sizes)
+}
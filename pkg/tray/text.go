@@ -0,0 +1,118 @@
+package tray
+
+import (
+	"log"
+
+	"github.com/knightpp/sni/pkg/menu"
+)
+
+// SetTitleFrom binds title to src, e.g. a translatable text from package
+// i18n. The title is updated and NewTitle signal is emitted when src
+// changes. SetTitle removes the binding.
+func (t *Tray) SetTitleFrom(src menu.Source[string]) *Tray {
+	t.textMu.Lock()
+	t.cancelTitle()
+	t.titleFrom = src
+	t.cancelTitle = src.Subscribe(t.refreshTitle)
+	t.textMu.Unlock()
+	t.refreshTitle()
+	return t
+}
+
+// SetToolTipFrom binds tooltip title and description to sources, nil
+// leaves that part as is. The tooltip is updated and NewToolTip signal is
+// emitted when sources change. SetToolTipRaw removes the bindings.
+func (t *Tray) SetToolTipFrom(title, description menu.Source[string]) *Tray {
+	t.textMu.Lock()
+	t.cancelToolTip()
+	t.toolTipFrom = [2]menu.Source[string]{title, description}
+	var cancels []func()
+	for _, src := range t.toolTipFrom {
+		if src != nil {
+			cancels = append(cancels, src.Subscribe(t.refreshToolTip))
+		}
+	}
+	t.cancelToolTip = func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+	t.textMu.Unlock()
+	t.refreshToolTip()
+	return t
+}
+
+// Relocalize resolves bound title, tooltip and menu texts again and
+// notifies the host about changes. Sources that notify about changes
+// themselves, like texts of i18n.Localizer, don't need it.
+func (t *Tray) Relocalize() {
+	t.refreshTitle()
+	t.refreshToolTip()
+	t.InvalidateMenu()
+}
+
+// unbindTitle removes binding set with SetTitleFrom.
+func (t *Tray) unbindTitle() {
+	t.textMu.Lock()
+	defer t.textMu.Unlock()
+	t.cancelTitle()
+	t.cancelTitle = func() {}
+	t.titleFrom = nil
+}
+
+// unbindToolTip removes bindings set with SetToolTipFrom.
+func (t *Tray) unbindToolTip() {
+	t.textMu.Lock()
+	defer t.textMu.Unlock()
+	t.cancelToolTip()
+	t.cancelToolTip = func() {}
+	t.toolTipFrom = [2]menu.Source[string]{}
+}
+
+func (t *Tray) refreshTitle() {
+	t.textMu.Lock()
+	defer t.textMu.Unlock()
+	if t.titleFrom == nil {
+		return
+	}
+	title := t.titleFrom.Get()
+	if t.getSniProp("Title") == title {
+		return
+	}
+	t.setSniProp("Title", title)
+	if t.sniProps != nil {
+		if err := t.SignalNewTitle(); err != nil {
+			log.Print("couldn't emit NewTitle: ", err)
+		}
+	}
+}
+
+func (t *Tray) refreshToolTip() {
+	t.textMu.Lock()
+	defer t.textMu.Unlock()
+	title, description := t.toolTipFrom[0], t.toolTipFrom[1]
+	if title == nil && description == nil {
+		return
+	}
+	tooltip, _ := t.getSniProp("ToolTip").(ToolTip)
+	changed := false
+	if title != nil {
+		if s := title.Get(); s != tooltip.Third {
+			tooltip.Third, changed = s, true
+		}
+	}
+	if description != nil {
+		if s := description.Get(); s != tooltip.Fourth {
+			tooltip.Fourth, changed = s, true
+		}
+	}
+	if !changed {
+		return
+	}
+	t.setSniProp("ToolTip", tooltip)
+	if t.sniProps != nil {
+		if err := t.SignalNewToolTip(); err != nil {
+			log.Print("couldn't emit NewToolTip: ", err)
+		}
+	}
+}
@@ -6,14 +6,19 @@ import (
 	"image"
 	"image/draw"
 	"log"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/knightpp/sni/generated/d_bus"
 	"github.com/knightpp/sni/generated/d_bus_menu"
 	"github.com/knightpp/sni/generated/status_notifier_item"
 	"github.com/knightpp/sni/generated/status_notifier_watcher"
+	"github.com/knightpp/sni/pkg/dispatch"
+	"github.com/knightpp/sni/pkg/icontheme"
 	"github.com/knightpp/sni/pkg/menu"
 	"github.com/knightpp/sni/pkg/sni"
+	"github.com/knightpp/sni/pkg/watcher"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
@@ -38,6 +43,37 @@ type Tray struct {
 	menuServer d_bus_menu.Dbusmenuer
 	// sniServer implements org.kde.StatusNotifierWatcher
 	sniServer status_notifier_item.StatusNotifierItemer
+	// sniProps serves propsSni on dbus, it's nil until Setup is called
+	sniProps *prop.Properties
+	// menuProps serves propsMenu on dbus, it's nil until Setup is called
+	menuProps *prop.Properties
+	// iconTheme resolves icon names to pixmaps, nil disables resolving
+	iconTheme *icontheme.Theme
+	// resolvedPixmaps has pixmap props set by iconTheme rather than by the
+	// caller, they are cleared when their icon isn't found
+	resolvedPixmaps map[string]bool
+
+	// menuStatusMu guards fields below, see SetMenuStatusFor
+	menuStatusMu sync.Mutex
+	// menuStatusTimer restores menuStatusBase, it's nil if nothing to restore
+	menuStatusTimer *time.Timer
+	menuStatusBase  MenuStatus
+
+	// textMu guards fields below, see SetTitleFrom
+	textMu        sync.Mutex
+	titleFrom     menu.Source[string]
+	cancelTitle   func()
+	toolTipFrom   [2]menu.Source[string]
+	cancelToolTip func()
+
+	// fallbackPollInterval is how often the fallback watcher looks for
+	// queued watchers, it doesn't change after NewTrayWithConn
+	fallbackPollInterval time.Duration
+	// fallbackMu guards fields below, see SetFallbackWatcher
+	fallbackMu       sync.Mutex
+	fallbackEnabled  bool
+	fallback         *watcher.Watcher
+	stopFallbackPoll func()
 }
 
 // NewTray allocates new Tray. Note: this function doesn't communicate through
@@ -55,13 +91,22 @@ func NewTray(id, title string, itemTree menu.ItemTree) (*Tray, error) {
 // NewTray allocates new Tray. Note: this function doesn't communicate through
 // dbus, to "start tray" you should call .Setup method
 func NewTrayWithConn(conn *dbus.Conn, id, title string, itemTree menu.ItemTree) *Tray {
-	return &Tray{
+	t := &Tray{
 		conn:       conn,
 		propsSni:   makePropsSni(id, title),
 		propsMenu:  makePropsMenu(),
 		menuServer: menu.NewMenuServer(itemTree),
 		sniServer:  sni.NewSniServer(),
+
+		cancelTitle:   func() {},
+		cancelToolTip: func() {},
+
+		fallbackPollInterval: fallbackPollInterval,
 	}
+	// Menu handlers and activation callbacks of the tray don't overlap,
+	// other trays don't wait for them.
+	t.SetDispatcher(new(dispatch.Serial))
+	return t
 }
 
 func (t *Tray) SetSniServer(impl status_notifier_item.StatusNotifierItemer) *Tray {
@@ -74,8 +119,84 @@ func (t *Tray) SetMenuServer(impl d_bus_menu.Dbusmenuer) *Tray {
 	return t
 }
 
+// SetDispatcher sets where menu handlers and activation callbacks run, see
+// package dispatch. By default they run one at a time on D-Bus goroutines,
+// each tray has its own dispatch.Serial.
+// Custom servers set with SetSniServer or SetMenuServer are left as is.
+func (t *Tray) SetDispatcher(d dispatch.Dispatcher) *Tray {
+	if ss, ok := t.sniServer.(*sni.SniServer); ok {
+		ss.SetDispatcher(d)
+	}
+	if ms, ok := t.menuServer.(*menu.MenuServer); ok {
+		ms.SetDispatcher(d)
+	}
+	return t
+}
+
+// OnActivate sets callback for primary activation, see
+// sni.SniServer.OnActivate. It does nothing if a custom server was set with
+// SetSniServer, the same goes for other activation callbacks.
+func (t *Tray) OnActivate(fn func(x, y int32)) *Tray {
+	if ss, ok := t.sniServer.(*sni.SniServer); ok {
+		ss.OnActivate(fn)
+	}
+	return t
+}
+
+// OnSecondaryActivate sets callback for secondary activation.
+func (t *Tray) OnSecondaryActivate(fn func(x, y int32)) *Tray {
+	if ss, ok := t.sniServer.(*sni.SniServer); ok {
+		ss.OnSecondaryActivate(fn)
+	}
+	return t
+}
+
+// OnContextMenu sets callback for requests to show a context menu.
+func (t *Tray) OnContextMenu(fn func(x, y int32)) *Tray {
+	if ss, ok := t.sniServer.(*sni.SniServer); ok {
+		ss.OnContextMenu(fn)
+	}
+	return t
+}
+
+// OnScroll sets callback for mouse wheel.
+func (t *Tray) OnScroll(fn func(delta int32, orientation string)) *Tray {
+	if ss, ok := t.sniServer.(*sni.SniServer); ok {
+		ss.OnScroll(fn)
+	}
+	return t
+}
+
+// SetMenu replaces the menu, see menu.MenuServer.SetTree. It does nothing if
+// a custom menu server was set with SetMenuServer.
+func (t *Tray) SetMenu(tree menu.ItemTree) {
+	if ms, ok := t.menuServer.(*menu.MenuServer); ok {
+		ms.SetTree(tree)
+	}
+}
+
+// RequestMenuActivation asks the host to show item, see
+// menu.MenuServer.RequestActivation.
+func (t *Tray) RequestMenuActivation(item *menu.Item, timestamp uint32) error {
+	ms, ok := t.menuServer.(*menu.MenuServer)
+	if !ok {
+		return fmt.Errorf("custom menu server doesn't support activation requests")
+	}
+	return ms.RequestActivation(item, timestamp)
+}
+
+// InvalidateMenu recomputes menu properties bound to sources, see
+// menu.MenuServer.Invalidate. It does nothing if a custom menu server was set
+// with SetMenuServer.
+func (t *Tray) InvalidateMenu() {
+	if ms, ok := t.menuServer.(*menu.MenuServer); ok {
+		ms.Invalidate()
+	}
+}
+
 // Close closes underlying dbus connection
 func (t *Tray) Close() error {
+	t.stopFallback()
 	if t.conn != nil {
 		return t.conn.Close()
 	}
@@ -101,7 +222,11 @@ func (t *Tray) Setup() error {
 	if err != nil {
 		return err
 	}
-	err = d_bus_menu.ExportDbusmenu(t.conn, MENU_PATH, t.menuServer)
+	if ms, ok := t.menuServer.(*menu.MenuServer); ok {
+		err = ms.Export(t.conn, MENU_PATH)
+	} else {
+		err = d_bus_menu.ExportDbusmenu(t.conn, MENU_PATH, t.menuServer)
+	}
 	if err != nil {
 		return err
 	}
@@ -110,13 +235,13 @@ func (t *Tray) Setup() error {
 
 	props := make(map[string]map[string]*prop.Prop)
 	props[SNI_INTERFACE_NAME] = t.propsSni
-	_, err = prop.Export(t.conn, SNI_PATH, props)
+	t.sniProps, err = prop.Export(t.conn, SNI_PATH, props)
 	if err != nil {
 		return err
 	}
 	props = make(map[string]map[string]*prop.Prop)
-	props[DBUSMENU_INTERFACE_NAME] = t.propsMenu
-	_, err = prop.Export(t.conn, MENU_PATH, props)
+	props[DBUSMENU_INTERFACE_NAME] = silenced(t.propsMenu)
+	t.menuProps, err = prop.Export(t.conn, MENU_PATH, props)
 	if err != nil {
 		return err
 	}
@@ -151,6 +276,9 @@ func (t *Tray) Setup() error {
 	}
 	/*--------------- END-INTROSPECTION ---------------*/
 
+	if err = t.ensureWatcher(); err != nil {
+		return err
+	}
 	if err = register(t.conn, name); err != nil {
 		return err
 	}
@@ -172,7 +300,8 @@ func register(conn *dbus.Conn, service string) error {
 	return err
 }
 
-// listen blocks forever
+// listen blocks until the connection is closed. It registers the item again
+// when a new watcher appears.
 func (t *Tray) listen(appName string) error {
 	err := d_bus.AddMatchSignal(t.conn, &d_bus.DBus_NameOwnerChangedSignal{})
 	if err != nil {
@@ -183,30 +312,99 @@ func (t *Tray) listen(appName string) error {
 	for sig := range ch {
 		s, err := d_bus.LookupSignal(sig)
 		if err != nil {
-			return err
+			// Not a bus signal.
+			continue
 		}
 		switch sig := s.(type) {
 		case *d_bus.DBus_NameOwnerChangedSignal:
 			name := sig.Body.V0
-			// oldOwner := sig.Body.V1
 			newOwner := sig.Body.V2
-			if name == "org.kde.StatusNotifierWatcher" {
-				if newOwner == "" {
-					return fmt.Errorf("stop")
-				} else {
-					log.Printf("Registering !!!")
-					if err := register(t.conn, appName); err != nil {
-						return err
-					}
+			if name != watcher.Name {
+				continue
+			}
+			if newOwner == "" {
+				if err := t.ensureWatcher(); err != nil {
+					log.Print("couldn't start fallback StatusNotifierWatcher: ", err)
 				}
+				continue
+			}
+			if err := register(t.conn, appName); err != nil {
+				log.Print("couldn't register with StatusNotifierWatcher: ", err)
+			}
+		case *d_bus.DBus_NameLostSignal:
+			// Replaced by a real watcher.
+			if sig.Body.V0 == watcher.Name {
+				t.stopFallback()
 			}
-		default:
-			return fmt.Errorf("unknown signal: %+v", sig)
 		}
 	}
 	return nil
 }
 
+// setSniProp sets StatusNotifierItem property. prop.Export copies the map, so
+// after Setup the value must go through exported properties.
+func (t *Tray) setSniProp(name string, value interface{}) {
+	delete(t.resolvedPixmaps, name)
+	if t.sniProps != nil {
+		t.sniProps.SetMust(SNI_INTERFACE_NAME, name, value)
+		return
+	}
+	t.propsSni[name].Value = value
+}
+
+// getSniProp returns StatusNotifierItem property value.
+func (t *Tray) getSniProp(name string) interface{} {
+	if t.sniProps != nil {
+		return t.sniProps.GetMust(SNI_INTERFACE_NAME, name)
+	}
+	return t.propsSni[name].Value
+}
+
+// setMenuProp sets dbusmenu property, see setSniProp. Changes of properties
+// with prop.EmitTrue are announced here rather than by prop.Properties, so
+// that failing to emit PropertiesChanged, e.g. on a closed connection, is
+// only logged.
+func (t *Tray) setMenuProp(name string, value interface{}) {
+	if t.menuProps == nil {
+		t.propsMenu[name].Value = value
+		return
+	}
+	t.menuProps.SetMust(DBUSMENU_INTERFACE_NAME, name, value)
+	if t.propsMenu[name].Emit != prop.EmitTrue {
+		return
+	}
+	err := t.conn.Emit(MENU_PATH, "org.freedesktop.DBus.Properties.PropertiesChanged",
+		DBUSMENU_INTERFACE_NAME, map[string]dbus.Variant{name: dbus.MakeVariant(value)}, []string{})
+	if err != nil {
+		log.Printf("couldn't announce menu property %s: %v", name, err)
+	}
+}
+
+// silenced returns copies of props that never emit PropertiesChanged.
+func silenced(props map[string]*prop.Prop) map[string]*prop.Prop {
+	out := make(map[string]*prop.Prop, len(props))
+	for name, p := range props {
+		c := *p
+		if c.Emit == prop.EmitTrue {
+			c.Emit = prop.EmitFalse
+		}
+		out[name] = &c
+	}
+	return out
+}
+
+// getMenuProp returns dbusmenu property value.
+func (t *Tray) getMenuProp(name string) interface{} {
+	if t.menuProps != nil {
+		return t.menuProps.GetMust(DBUSMENU_INTERFACE_NAME, name)
+	}
+	return t.propsMenu[name].Value
+}
+
+// pixmapSizes are sizes scalable icons are rendered at, hosts choose the one
+// that fits the panel best.
+var pixmapSizes = []int{16, 22, 24, 32, 48, 64}
+
 func imageToArgb32(src image.Image) Pixmap {
 	b := src.Bounds()
 	width := b.Dx()
@@ -228,3 +426,18 @@ func imageToArgb32(src image.Image) Pixmap {
 		Data:   buf,
 	}
 }
+
+// Image converts ARGB32 data of the pixmap to an image. It fails if data
+// doesn't match the size.
+func (p Pixmap) Image() (*image.NRGBA, error) {
+	if p.Width < 0 || p.Heigth < 0 || len(p.Data) != int(p.Width)*int(p.Heigth)*4 {
+		return nil, fmt.Errorf("pixmap %dx%d has %d bytes of data",
+			p.Width, p.Heigth, len(p.Data))
+	}
+	m := image.NewNRGBA(image.Rect(0, 0, int(p.Width), int(p.Heigth)))
+	for i := 0; i < len(p.Data); i += 4 {
+		a, r, g, b := p.Data[i], p.Data[i+1], p.Data[i+2], p.Data[i+3]
+		m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = r, g, b, a
+	}
+	return m, nil
+}
@@ -11,20 +11,23 @@ import (
 // SetId sets an id that should be unique for this application and consistent
 // between sessions, such as the application name itself.
 func (t *Tray) SetId(id string) *Tray {
-	t.propsSni["Id"].Value = id
+	t.setSniProp("Id", id)
 	return t
 }
 
 // SetTitle sets a name that describes the application, it can be more
-// descriptive than Id.
+// descriptive than Id. See also SetTitleFrom.
 func (t *Tray) SetTitle(title string) *Tray {
-	t.propsSni["Title"].Value = title
+	t.unbindTitle()
+	t.setSniProp("Title", title)
 	return t
 }
 
-// SetIconName sets StatusNotifierItem IconName property
+// SetIconName sets StatusNotifierItem IconName property. If icon theme is
+// set, IconPixmap is updated as well, see SetIconTheme.
 func (t *Tray) SetIconName(name string) *Tray {
-	t.propsSni["IconName"].Value = name
+	t.setSniProp("IconName", name)
+	t.resolveIcon(name, "IconPixmap")
 	return t
 }
 
@@ -33,9 +36,9 @@ func (t *Tray) SetIconName(name string) *Tray {
 //
 // Note: see SetIconPixmapRaw
 func (t *Tray) SetIconPixmap(src image.Image) *Tray {
-	t.propsSni["IconPixmap"].Value = []Pixmap{
+	t.setSniProp("IconPixmap", []Pixmap{
 		imageToArgb32(src),
-	}
+	})
 	return t
 }
 
@@ -43,17 +46,13 @@ func (t *Tray) SetIconPixmap(src image.Image) *Tray {
 //
 // Note: see SetIconPixmap for higher level abstraction
 func (t *Tray) SetIconPixmapRaw(pixmaps []Pixmap) *Tray {
-	t.propsSni["IconPixmap"].Value = pixmaps
+	t.setSniProp("IconPixmap", pixmaps)
 	return t
 }
 
 // GetIconName returns StatusNotifierItem IconName property
 func (t *Tray) GetIconName() string {
-	v, ok := t.propsSni["IconName"]
-	if !ok {
-		panic("GetIconName(): no such value in a map")
-	}
-	s, ok := v.Value.(string)
+	s, ok := t.getSniProp("IconName").(string)
 	if !ok {
 		panic("GetIconName(): value is not string")
 	}
@@ -62,19 +61,21 @@ func (t *Tray) GetIconName() string {
 
 // SetWindowId sets WindowId property
 func (t *Tray) SetWindowId(id int32) *Tray {
-	t.propsSni["WindowId"].Value = id
+	t.setSniProp("WindowId", id)
 	return t
 }
 
 // SetItemIsMenu sets ItemIsMenu property
 func (t *Tray) SetItemIsMenu(b bool) *Tray {
-	t.propsSni["ItemIsMenu"].Value = b
+	t.setSniProp("ItemIsMenu", b)
 	return t
 }
 
-// SetOverlayIconName is a property of StatusNotifierItem
+// SetOverlayIconName is a property of StatusNotifierItem. If icon theme is
+// set, OverlayIconPixmap is updated as well, see SetIconTheme.
 func (t *Tray) SetOverlayIconName(name string) *Tray {
-	t.propsSni["OverlayIconName"].Value = name
+	t.setSniProp("OverlayIconName", name)
+	t.resolveIcon(name, "OverlayIconPixmap")
 	return t
 }
 
@@ -83,21 +84,23 @@ func (t *Tray) SetOverlayIconName(name string) *Tray {
 //
 // Note: see SetOverlayIconPixmapRaw
 func (t *Tray) SetOverlayIconPixmap(src image.Image) *Tray {
-	t.propsSni["OverlayIconPixmap"].Value = []Pixmap{
+	t.setSniProp("OverlayIconPixmap", []Pixmap{
 		imageToArgb32(src),
-	}
+	})
 	return t
 }
 
 // SetAttentionIconName sets StatusNotifierItem AttentionIconName prop.
 func (t *Tray) SetOverlayIconPixmapRaw(pixmaps []Pixmap) *Tray {
-	t.propsSni["OverlayIconPixmap"].Value = pixmaps
+	t.setSniProp("OverlayIconPixmap", pixmaps)
 	return t
 }
 
-// SetAttentionIconName sets StatusNotifierItem AttentionIconName prop.
+// SetAttentionIconName sets StatusNotifierItem AttentionIconName prop. If
+// icon theme is set, AttentionIconPixmap is updated as well, see SetIconTheme.
 func (t *Tray) SetAttentionIconName(name string) *Tray {
-	t.propsSni["AttentionIconName"].Value = name
+	t.setSniProp("AttentionIconName", name)
+	t.resolveIcon(name, "AttentionIconPixmap")
 	return t
 }
 
@@ -106,21 +109,23 @@ func (t *Tray) SetAttentionIconName(name string) *Tray {
 //
 // Note: see SetOverlayIconPixmapRaw
 func (t *Tray) SetAttentionIconPixmap(src image.Image) *Tray {
-	t.propsSni["AttentionIconPixmap"].Value = []Pixmap{
+	t.setSniProp("AttentionIconPixmap", []Pixmap{
 		imageToArgb32(src),
-	}
+	})
 	return t
 }
 
 // SetAttentionMovieName sets StatusNotifierItem AttentionMovieName prop.
 func (t *Tray) SetAttentionMovieName(name string) *Tray {
-	t.propsSni["AttentionMovieName"].Value = name
+	t.setSniProp("AttentionMovieName", name)
 	return t
 }
 
-// SetToolTipRaw sets StatusNotifierItem ToolTip prop.
+// SetToolTipRaw sets StatusNotifierItem ToolTip prop. See also
+// SetToolTipFrom.
 func (t *Tray) SetToolTipRaw(tooltip ToolTip) *Tray {
-	t.propsSni["ToolTip"].Value = tooltip
+	t.unbindToolTip()
+	t.setSniProp("ToolTip", tooltip)
 	return t
 }
 
@@ -142,15 +147,18 @@ func (t *Tray) SetToolTipRaw(tooltip ToolTip) *Tray {
 // intervention. Visualizations should emphasize in some way the items with
 // NeedsAttention status.
 func (t *Tray) SetSniStatus(status sni.Status) *Tray {
-	t.propsSni["Status"].Value = status
+	t.setSniProp("Status", status)
 	return t
 }
 
 // Represents the way the text direction of the application. This
 // allows the server to handle mismatches intelligently. For left-
 // to-right the string is "ltr" for right-to-left it is "rtl".
+//
+// Default value is detected from the locale, see DetectTextDirection.
+// Changes are announced with PropertiesChanged signal.
 func (t *Tray) SetMenuTextDirection(dir TextDirection) *Tray {
-	t.propsMenu["TextDirection"].Value = dir
+	t.setMenuProp("TextDirection", dir)
 	return t
 }
 
@@ -162,8 +170,16 @@ func (t *Tray) SetMenuTextDirection(dir TextDirection) *Tray {
 // - "normal" in almost all cases and
 //
 // - "notice" when they should have a higher priority to be shown.
+//
+// See also SetMenuStatusFor.
 func (t *Tray) SetMenuStatus(status MenuStatus) *Tray {
-	t.propsMenu["Status"].Value = status
+	t.menuStatusMu.Lock()
+	defer t.menuStatusMu.Unlock()
+	if t.menuStatusTimer != nil {
+		t.menuStatusTimer.Stop()
+		t.menuStatusTimer = nil
+	}
+	t.setMenuProp("Status", status)
 	return t
 }
 
@@ -172,14 +188,14 @@ func (t *Tray) SetMenuStatus(status MenuStatus) *Tray {
 // theme, but additional ones are often added by applications for
 // app specific icons.
 func (t *Tray) SetMenuIconThemePath(path []string) *Tray {
-	t.propsMenu["IconThemePath"].Value = path
+	t.setMenuProp("IconThemePath", path)
 	return t
 }
 
 // SetCategory sets a category of the StatusNotifierItem.
 // Default value is ApplicationStatus.
 func (t *Tray) SetCategory(cat sni.Category) *Tray {
-	t.propsSni["Category"].Value = cat
+	t.setSniProp("Category", cat)
 	return t
 }
 
@@ -187,7 +203,7 @@ func (t *Tray) SetCategory(cat sni.Category) *Tray {
 //
 // You shouldn't use this function if you don't know what it is.
 func (t *Tray) SetMenuPath(path dbus.ObjectPath) *Tray {
-	t.propsSni["Menu"].Value = path
+	t.setSniProp("Menu", path)
 	return t
 }
 
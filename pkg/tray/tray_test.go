@@ -0,0 +1,50 @@
+package tray_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/menu"
+	"github.com/knightpp/sni/pkg/tray"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMenuPropertiesChanged(t *testing.T) {
+	address := bustest.Start(t)
+
+	conn, err := dbus.Connect(address)
+	require.NoError(t, err)
+	tr := tray.NewTrayWithConn(conn, "test", "Test", menu.NewItem().Build()).
+		SetFallbackWatcher(true)
+	require.NoError(t, tr.Setup())
+
+	other, err := dbus.Connect(address)
+	require.NoError(t, err)
+	defer other.Close()
+	require.NoError(t, other.AddMatchSignal(
+		dbus.WithMatchObjectPath(tray.MENU_PATH),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	))
+	signals := make(chan *dbus.Signal, 4)
+	other.Signal(signals)
+
+	tr.SetMenuStatus(tray.MenuStatusNotice)
+	select {
+	case sig := <-signals:
+		require.Len(t, sig.Body, 3)
+		changed := sig.Body[1].(map[string]dbus.Variant)
+		assert.Equal(t, tray.MenuStatusNotice, changed["Status"].Value())
+	case <-time.After(2 * time.Second):
+		t.Fatal("PropertiesChanged wasn't emitted")
+	}
+
+	// Changes can't be announced on a closed connection, but they are
+	// still applied.
+	require.NoError(t, tr.Close())
+	assert.NotPanics(t, func() { tr.SetMenuStatus(tray.MenuStatusNormal) })
+}
@@ -0,0 +1,351 @@
+// Package watcher implements org.kde.StatusNotifierWatcher, the service
+// that keeps track of tray items and of hosts that show them.
+//
+// Desktops like KDE Plasma run a watcher, window managers usually don't.
+// Panels for such environments can embed Watcher:
+//
+//	w := watcher.New(conn)
+//	if err := w.Start(); err != nil {
+//		return err
+//	}
+//	defer w.Close()
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/knightpp/sni/generated/d_bus"
+	"github.com/knightpp/sni/generated/status_notifier_watcher"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// Name is the well-known bus name of the watcher.
+	Name = "org.kde.StatusNotifierWatcher"
+	// Path is the object path of the watcher.
+	Path = dbus.ObjectPath("/StatusNotifierWatcher")
+	// ProtocolVersion is the version of the protocol the watcher implements.
+	ProtocolVersion int32 = 0
+
+	itemPath = "/StatusNotifierItem"
+	iface    = status_notifier_watcher.InterfaceStatusNotifierWatcher
+)
+
+// ErrNameTaken is returned by Start when another watcher owns Name.
+var ErrNameTaken = errors.New("watcher: " + Name + " is owned by another process")
+
+// Watcher tracks registered items and hosts. Items and hosts are removed
+// when their bus names disappear.
+type Watcher struct {
+	conn *dbus.Conn
+	// mu guards fields below
+	mu    sync.Mutex
+	items []string
+	hosts []string
+	props *prop.Properties
+	// signals receives NameOwnerChanged, it's nil until Export
+	signals chan *dbus.Signal
+	done    chan struct{}
+	// ownsName is set when Start has acquired Name
+	ownsName bool
+}
+
+// New returns Watcher that serves on conn, it doesn't communicate through
+// D-Bus until Start or Export is called.
+func New(conn *dbus.Conn) *Watcher {
+	return &Watcher{conn: conn}
+}
+
+// Start exports the watcher and requests Name. It returns ErrNameTaken if
+// another watcher is running. On error the watcher is closed.
+func (w *Watcher) Start() error {
+	if err := w.Export(); err != nil {
+		return err
+	}
+	reply, err := w.conn.RequestName(Name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+		w.Close()
+		return ErrNameTaken
+	}
+	w.mu.Lock()
+	w.ownsName = true
+	w.mu.Unlock()
+	return nil
+}
+
+// Export exports the watcher object at Path and starts tracking bus names,
+// but doesn't request Name. It's for callers that manage the name
+// themselves.
+func (w *Watcher) Export() error {
+	err := w.conn.ExportMethodTable(map[string]interface{}{
+		"RegisterStatusNotifierItem": w.registerItem,
+		"RegisterStatusNotifierHost": w.registerHost,
+	}, Path, iface)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Changes are announced by setProp.
+	w.props, err = prop.Export(w.conn, Path, map[string]map[string]*prop.Prop{
+		iface: {
+			"RegisteredStatusNotifierItems":  {Value: []string{}, Emit: prop.EmitFalse},
+			"IsStatusNotifierHostRegistered": {Value: false, Emit: prop.EmitFalse},
+			"ProtocolVersion":                {Value: ProtocolVersion, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	node := introspect.Node{
+		Name: string(Path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			status_notifier_watcher.IntrospectDataStatusNotifierWatcher,
+		},
+	}
+	err = w.conn.Export(introspect.NewIntrospectable(&node), Path,
+		"org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return err
+	}
+
+	err = d_bus.AddMatchSignal(w.conn, &d_bus.DBus_NameOwnerChangedSignal{})
+	if err != nil {
+		return err
+	}
+	w.signals = make(chan *dbus.Signal, 16)
+	w.done = make(chan struct{})
+	w.conn.Signal(w.signals)
+	go w.listen(w.signals, w.done)
+	return nil
+}
+
+// Close stops serving and releases Name if Start has acquired it.
+// Registered items and hosts are forgotten.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	signals, done, ownsName := w.signals, w.done, w.ownsName
+	w.signals = nil
+	w.ownsName = false
+	w.items, w.hosts = nil, nil
+	w.mu.Unlock()
+	if signals == nil {
+		return nil
+	}
+	if !w.conn.Connected() {
+		// Closed connection closes signal channels itself.
+		<-done
+		return nil
+	}
+	w.conn.RemoveSignal(signals)
+	close(signals)
+	<-done
+
+	var errs []error
+	if ownsName {
+		if _, err := w.conn.ReleaseName(Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range []string{iface, "org.freedesktop.DBus.Properties",
+		"org.freedesktop.DBus.Introspectable"} {
+		if err := w.conn.Export(nil, Path, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, d_bus.RemoveMatchSignal(w.conn, &d_bus.DBus_NameOwnerChangedSignal{}))
+	return errors.Join(errs...)
+}
+
+// Items returns registered items in order of registration. Items are bus
+// names followed by object paths, e.g. ":1.42/StatusNotifierItem".
+func (w *Watcher) Items() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.items...)
+}
+
+// Hosts returns bus names of registered hosts.
+func (w *Watcher) Hosts() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.hosts...)
+}
+
+// registerItem is RegisterStatusNotifierItem method. service is either a
+// bus name, the item is at /StatusNotifierItem then, or an object path on
+// the sender's connection as libappindicator does.
+func (w *Watcher) registerItem(sender dbus.Sender, service string) *dbus.Error {
+	busName, path := string(sender), service
+	if !strings.HasPrefix(service, "/") {
+		busName, path = service, itemPath
+	}
+	if busName == "" {
+		busName = string(sender)
+	}
+	if err := w.checkOwner(sender, busName); err != nil {
+		return err
+	}
+	item := busName + path
+
+	w.mu.Lock()
+	if contains(w.items, item) {
+		w.mu.Unlock()
+		return nil
+	}
+	w.items = append(w.items, item)
+	w.setProp("RegisteredStatusNotifierItems", append([]string(nil), w.items...))
+	w.mu.Unlock()
+
+	w.emit(&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemRegisteredSignal{
+		Path: Path,
+		Body: &status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemRegisteredSignalBody{
+			V0: item,
+		},
+	})
+	return nil
+}
+
+// registerHost is RegisterStatusNotifierHost method.
+func (w *Watcher) registerHost(sender dbus.Sender, service string) *dbus.Error {
+	if service == "" {
+		service = string(sender)
+	}
+	if err := w.checkOwner(sender, service); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if contains(w.hosts, service) {
+		w.mu.Unlock()
+		return nil
+	}
+	w.hosts = append(w.hosts, service)
+	if len(w.hosts) == 1 {
+		w.setProp("IsStatusNotifierHostRegistered", true)
+	}
+	w.mu.Unlock()
+
+	w.emit(&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostRegisteredSignal{
+		Path: Path,
+		Body: &status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostRegisteredSignalBody{},
+	})
+	return nil
+}
+
+// checkOwner fails if nobody owns name, otherwise the watcher would never
+// learn that the name is gone.
+func (w *Watcher) checkOwner(sender dbus.Sender, name string) *dbus.Error {
+	if name == string(sender) {
+		return nil
+	}
+	var hasOwner bool
+	err := w.conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, name).Store(&hasOwner)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if !hasOwner {
+		return dbus.MakeFailedError(fmt.Errorf("name %q has no owner", name))
+	}
+	return nil
+}
+
+func (w *Watcher) listen(signals <-chan *dbus.Signal, done chan<- struct{}) {
+	defer close(done)
+	for sig := range signals {
+		s, err := d_bus.LookupSignal(sig)
+		if err != nil {
+			continue
+		}
+		if s, ok := s.(*d_bus.DBus_NameOwnerChangedSignal); ok && s.Body.V2 == "" {
+			w.nameLost(s.Body.V0)
+		}
+	}
+}
+
+// nameLost removes items and hosts of name.
+func (w *Watcher) nameLost(name string) {
+	w.mu.Lock()
+	var removed []string
+	items := w.items[:0]
+	for _, item := range w.items {
+		if strings.HasPrefix(item, name+"/") {
+			removed = append(removed, item)
+		} else {
+			items = append(items, item)
+		}
+	}
+	w.items = items
+	if len(removed) > 0 {
+		w.setProp("RegisteredStatusNotifierItems", append([]string(nil), w.items...))
+	}
+
+	hadHosts := len(w.hosts) > 0
+	hosts := w.hosts[:0]
+	for _, host := range w.hosts {
+		if host != name {
+			hosts = append(hosts, host)
+		}
+	}
+	w.hosts = hosts
+	lastHost := hadHosts && len(w.hosts) == 0
+	if lastHost {
+		w.setProp("IsStatusNotifierHostRegistered", false)
+	}
+	w.mu.Unlock()
+
+	for _, item := range removed {
+		w.emit(&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemUnregisteredSignal{
+			Path: Path,
+			Body: &status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemUnregisteredSignalBody{
+				V0: item,
+			},
+		})
+	}
+	if lastHost {
+		w.emit(&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostUnregisteredSignal{
+			Path: Path,
+			Body: &status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostUnregisteredSignalBody{},
+		})
+	}
+}
+
+// setProp sets a watcher property and announces the change. Failing to
+// announce it, e.g. because the connection is closed, is only logged.
+func (w *Watcher) setProp(name string, value interface{}) {
+	w.props.SetMust(iface, name, value)
+	err := w.conn.Emit(Path, "org.freedesktop.DBus.Properties.PropertiesChanged",
+		iface, map[string]dbus.Variant{name: dbus.MakeVariant(value)}, []string{})
+	if err != nil {
+		log.Printf("couldn't announce %s: %v", name, err)
+	}
+}
+
+func (w *Watcher) emit(s status_notifier_watcher.Signal) {
+	if err := status_notifier_watcher.Emit(w.conn, s); err != nil {
+		log.Printf("couldn't emit %s: %v", s.Name(), err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package watcher_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knightpp/sni/generated/status_notifier_watcher"
+	"github.com/knightpp/sni/internal/bustest"
+	"github.com/knightpp/sni/pkg/watcher"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	assert := assert.New(t)
+	address := bustest.Start(t)
+	w := watcher.New(bustest.Connect(t, address))
+	require.NoError(t, w.Start())
+	defer w.Close()
+
+	taken := bustest.Connect(t, address)
+	assert.ErrorIs(watcher.New(taken).Start(), watcher.ErrNameTaken)
+	// The watcher that failed to start isn't left exported.
+	assert.Error(taken.Object(taken.Names()[0], watcher.Path).
+		Call("org.freedesktop.DBus.Properties.GetAll", 0, watcher.Name).Err)
+
+	client := bustest.Connect(t, address)
+	sigs := make(chan *dbus.Signal, 10)
+	client.Signal(sigs)
+	require.NoError(t, status_notifier_watcher.AddMatchSignal(client,
+		&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemRegisteredSignal{}))
+	require.NoError(t, client.AddMatchSignal(
+		dbus.WithMatchObjectPath(watcher.Path),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	))
+	proxy := status_notifier_watcher.NewStatusNotifierWatcher(
+		client.Object(watcher.Name, watcher.Path))
+	ctx := context.Background()
+
+	item := bustest.Connect(t, address)
+	_, err := item.RequestName("org.kde.StatusNotifierItem-1-1", 0)
+	require.NoError(t, err)
+	require.NoError(t, status_notifier_watcher.NewStatusNotifierWatcher(
+		item.Object(watcher.Name, watcher.Path)).
+		RegisterStatusNotifierItem(ctx, "org.kde.StatusNotifierItem-1-1"))
+	// libappindicator registers object paths.
+	require.NoError(t, status_notifier_watcher.NewStatusNotifierWatcher(
+		item.Object(watcher.Name, watcher.Path)).
+		RegisterStatusNotifierItem(ctx, "/org/ayatana/NotificationItem/app"))
+	assert.Error(proxy.RegisterStatusNotifierItem(ctx, "org.example.Nobody"))
+
+	items, err := proxy.GetRegisteredStatusNotifierItems(ctx)
+	require.NoError(t, err)
+	assert.Equal([]string{
+		"org.kde.StatusNotifierItem-1-1/StatusNotifierItem",
+		item.Names()[0] + "/org/ayatana/NotificationItem/app",
+	}, items)
+	assert.Equal(items[0], waitItemRegistered(t, sigs))
+
+	require.NoError(t, proxy.RegisterStatusNotifierHost(ctx, client.Names()[0]))
+	assert.Equal(true, waitPropertyChanged(t, sigs, "IsStatusNotifierHostRegistered"))
+	registered, err := proxy.GetIsStatusNotifierHostRegistered(ctx)
+	require.NoError(t, err)
+	assert.True(registered)
+	version, err := proxy.GetProtocolVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(watcher.ProtocolVersion, version)
+
+	// Every host is announced.
+	require.NoError(t, status_notifier_watcher.AddMatchSignal(client,
+		&status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostRegisteredSignal{}))
+	require.NoError(t, proxy.RegisterStatusNotifierHost(ctx, item.Names()[0]))
+	waitHostRegistered(t, sigs)
+
+	// Items disappear with their connection.
+	require.NoError(t, item.Close())
+	assert.Eventually(func() bool { return len(w.Items()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestCloseKeepsForeignName(t *testing.T) {
+	conn := bustest.Connect(t, bustest.Start(t))
+	reply, err := conn.RequestName(watcher.Name, 0)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	// The watcher is only exported, the name was requested by the caller.
+	w := watcher.New(conn)
+	require.NoError(t, w.Export())
+	require.NoError(t, w.Close())
+
+	var owner string
+	require.NoError(t, conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0,
+		watcher.Name).Store(&owner))
+	assert.Equal(t, conn.Names()[0], owner)
+}
+
+// waitItemRegistered skips signals from the bus until the first
+// StatusNotifierItemRegistered.
+func waitItemRegistered(t *testing.T, sigs <-chan *dbus.Signal) string {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case sig := <-sigs:
+			s, err := status_notifier_watcher.LookupSignal(sig)
+			if err != nil {
+				continue
+			}
+			if s, ok := s.(*status_notifier_watcher.StatusNotifierWatcher_StatusNotifierItemRegisteredSignal); ok {
+				return s.Body.V0
+			}
+		case <-timeout:
+			t.Fatal("no StatusNotifierItemRegistered signal")
+		}
+	}
+}
+
+// waitHostRegistered skips signals from the bus until the first
+// StatusNotifierHostRegistered.
+func waitHostRegistered(t *testing.T, sigs <-chan *dbus.Signal) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case sig := <-sigs:
+			s, err := status_notifier_watcher.LookupSignal(sig)
+			if err != nil {
+				continue
+			}
+			if _, ok := s.(*status_notifier_watcher.StatusNotifierWatcher_StatusNotifierHostRegisteredSignal); ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no StatusNotifierHostRegistered signal")
+		}
+	}
+}
+
+// waitPropertyChanged skips signals from the bus until PropertiesChanged of
+// the named property and returns its new value.
+func waitPropertyChanged(t *testing.T, sigs <-chan *dbus.Signal, name string) interface{} {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case sig := <-sigs:
+			if sig.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || len(sig.Body) != 3 {
+				continue
+			}
+			changed, _ := sig.Body[1].(map[string]dbus.Variant)
+			if v, ok := changed[name]; ok {
+				return v.Value()
+			}
+		case <-timeout:
+			t.Fatalf("no PropertiesChanged signal for %s", name)
+		}
+	}
+}